@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ApiKeyPrefix       = "sr_"
+	ApiKeyPrefixLength = 12
+)
+
+var (
+	ErrApiKeyNotFound = errors.New("api key not found")
+	ErrApiKeyExpired  = errors.New("api key expired")
+	ErrApiKeyRevoked  = errors.New("api key revoked")
+)
+
+type ApiKey struct {
+	ID         uuid.UUID  `gorm:"primaryKey"`
+	UserID     uuid.UUID  `gorm:"column:userId"`
+	Name       string     `gorm:"column:name"`
+	Prefix     string     `gorm:"column:prefix"`
+	KeyHash    string     `gorm:"column:keyHash"`
+	Scopes     []string   `gorm:"column:scopes;serializer:json"`
+	CreatedAt  time.Time  `gorm:"column:createdAt"`
+	ExpiresAt  *time.Time `gorm:"column:expiresAt"`
+	LastUsedAt *time.Time `gorm:"column:lastUsedAt"`
+	RevokedAt  *time.Time `gorm:"column:revokedAt"`
+}
+
+func (ApiKey) TableName() string {
+	return "apiKey"
+}
+
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatedApiKey carries the plaintext key, it is returned only once, on creation
+type CreatedApiKey struct {
+	Key       *ApiKey
+	Plaintext string
+}
+
+type IApiKeyRepository interface {
+	Create(ctx context.Context, key *ApiKey) (*CreatedApiKey, error)
+	GetByKey(ctx context.Context, plaintext string) (*ApiKey, error)
+	GetByUser(ctx context.Context, userId uuid.UUID) ([]*ApiKey, error)
+	Revoke(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error
+}
@@ -2,13 +2,46 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 )
 
 const DefaultRole = "user"
 
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmUnknown  = "unknown"
+)
+
+var ErrPasswordHashChanged = errors.New("password hash was changed concurrently")
+
+type PasswordHashParams struct {
+	Cost        int    `json:"cost,omitempty"`
+	Memory      uint32 `json:"memory,omitempty"`
+	Iterations  uint32 `json:"iterations,omitempty"`
+	Parallelism uint8  `json:"parallelism,omitempty"`
+	SaltLength  uint32 `json:"saltLength,omitempty"`
+	KeyLength   uint32 `json:"keyLength,omitempty"`
+}
+
+type PasswordHash struct {
+	Hash      string
+	Algorithm string
+	Params    PasswordHashParams
+}
+
+type UserAuth struct {
+	domain.UserAuth
+	HashAlgorithm string
+	HashParams    PasswordHashParams
+}
+
 type IAuthRepository interface {
 	Register(ctx context.Context, authInfo *domain.User) (uuid.UUID, error)
-	GetByUsername(ctx context.Context, username string) (*domain.UserAuth, error)
+	GetByUsername(ctx context.Context, username string) (*UserAuth, error)
+	GetByEmail(ctx context.Context, email string) (*UserAuth, error)
+	GetByLogin(ctx context.Context, usernameOrEmail string) (*UserAuth, error)
+	UpdatePasswordHash(ctx context.Context, userId uuid.UUID, oldHash string, newHash *PasswordHash) error
 }
@@ -2,8 +2,10 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
+	"time"
 )
 
 type Comment struct {
@@ -14,10 +16,105 @@ type Comment struct {
 	Rating   int       `gorm:"column:rating"`
 }
 
+const MaxReplyDepth = 5
+
+var (
+	ErrReplyTooDeep      = errors.New("reply thread is too deep")
+	ErrForeignReplyRoot  = errors.New("parent reply belongs to another comment")
+	ErrUnknownModeration = errors.New("unknown comment moderation state")
+)
+
+type CommentReply struct {
+	ID         uuid.UUID     `gorm:"primaryKey"`
+	CommentID  uuid.UUID     `gorm:"column:commentId"`
+	ParentID   uuid.NullUUID `gorm:"column:parentId"`
+	AuthorID   uuid.UUID     `gorm:"column:author"`
+	Text       string        `gorm:"column:text"`
+	Depth      int           `gorm:"column:depth"`
+	CreatedAt  time.Time     `gorm:"column:createdAt"`
+	Moderation int           `gorm:"column:moderation"`
+}
+
+const (
+	CommentSortNewest = iota
+	CommentSortHighestRating
+	CommentSortLowestRating
+	CommentSortMostHelpful
+)
+
+const (
+	CommentVisible = iota
+	CommentFlagged
+	CommentHidden
+)
+
+type CommentRevision struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CommentID uuid.UUID `gorm:"column:commentId"`
+	Text      string    `gorm:"column:text"`
+	Rating    int       `gorm:"column:rating"`
+	EditedAt  time.Time `gorm:"column:editedAt"`
+}
+
+type CommentVote struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CommentID uuid.UUID `gorm:"column:commentId"`
+	UserID    uuid.UUID `gorm:"column:userId"`
+	Helpful   bool      `gorm:"column:helpful"`
+}
+
+// CommentListOptions narrows comment listings. Moderated comments and replies
+// are left out unless IncludeModerated is set or ViewerID wrote them
+type CommentListOptions struct {
+	Sort             int
+	Rating           int
+	ViewerID         uuid.UUID
+	IncludeModerated bool
+}
+
+type RatedComment struct {
+	Comment    *domain.Comment
+	CreatedAt  time.Time
+	Helpful    int
+	Unhelpful  int
+	Moderation int
+}
+
+type ModeratedComment struct {
+	Comment     *domain.Comment
+	Moderation  int
+	Reason      string
+	ModeratedAt time.Time
+}
+
+type ReplyNode struct {
+	Reply   *CommentReply
+	Replies []*ReplyNode
+}
+
+type CommentThread struct {
+	Comment      *domain.Comment
+	Moderation   int
+	RepliesCount int
+	Replies      []*ReplyNode
+}
+
 func (Comment) TableName() string {
 	return "comment"
 }
 
+func (CommentReply) TableName() string {
+	return "commentReply"
+}
+
+func (CommentRevision) TableName() string {
+	return "commentRevision"
+}
+
+func (CommentVote) TableName() string {
+	return "commentVote"
+}
+
 func ToCommentDB(comment *domain.Comment) *Comment {
 	return &Comment{
 		ID:       comment.ID,
@@ -39,10 +136,20 @@ func ToCommentBL(comment *Comment) *domain.Comment {
 }
 
 type ICommentRepository interface {
-	Create(ctx context.Context, comment *domain.Comment) error
+	Create(ctx context.Context, comment *domain.Comment) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.Comment, error)
 	GetBySaladAndUser(ctx context.Context, saladId uuid.UUID, userId uuid.UUID) (*domain.Comment, error)
 	GetAllBySaladID(ctx context.Context, saladId uuid.UUID, page int) ([]*domain.Comment, int, error)
 	Update(ctx context.Context, comment *domain.Comment) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	Reply(ctx context.Context, reply *CommentReply) (uuid.UUID, error)
+	GetThreads(ctx context.Context, saladId uuid.UUID, opts *CommentListOptions, page int) ([]*CommentThread, int, error)
+	CountReplies(ctx context.Context, commentId uuid.UUID) (int, error)
+	Vote(ctx context.Context, commentId uuid.UUID, userId uuid.UUID, helpful bool) error
+	RemoveVote(ctx context.Context, commentId uuid.UUID, userId uuid.UUID) error
+	GetAllBySaladIDSorted(ctx context.Context, saladId uuid.UUID, opts *CommentListOptions, page int) ([]*RatedComment, int, error)
+	GetRevisions(ctx context.Context, commentId uuid.UUID) ([]*CommentRevision, error)
+	SetModeration(ctx context.Context, commentId uuid.UUID, moderation int, reason string) error
+	SetReplyModeration(ctx context.Context, replyId uuid.UUID, moderation int, reason string) error
+	GetFlagged(ctx context.Context, page int) ([]*ModeratedComment, int, error)
 }
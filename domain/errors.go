@@ -0,0 +1,8 @@
+package domain
+
+import "errors"
+
+// ErrAlreadyExists is returned by Create when a row with the ID chosen by the
+// caller is already stored, which means an earlier attempt of the same Create
+// succeeded and the ID can be used as is
+var ErrAlreadyExists = errors.New("row with this id already exists")
@@ -2,10 +2,17 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrMergeIntoItself          = errors.New("ingredient cannot be merged into itself")
+	ErrIncompatibleMeasurements = errors.New("measurements cannot be converted to each other")
+	ErrEmptyAlias               = errors.New("ingredient alias is empty")
+)
+
 type Ingredient struct {
 	ID       uuid.UUID `gorm:"primaryKey"`
 	TypeID   uuid.UUID `gorm:"column:type"`
@@ -18,7 +25,37 @@ type IngredientLink struct {
 	RecipeId     uuid.UUID `gorm:"column:recipeId"`
 	IngredientId uuid.UUID `gorm:"column:ingredientId"`
 	Measurement  uuid.UUID `gorm:"column:measurement"`
-	amount       int       `gorm:"column:amount"`
+	Amount       int       `gorm:"column:amount"`
+}
+
+type IngredientAlias struct {
+	ID           uuid.UUID `gorm:"primaryKey"`
+	IngredientId uuid.UUID `gorm:"column:ingredientId"`
+	Alias        string    `gorm:"column:alias"`
+}
+
+// MergedIngredientLink describes two links of one recipe summed into one.
+// AmountBefore and AmountAdded are the amounts of the kept and the removed
+// link as they were stored, in MeasurementBefore and MeasurementAdded.
+// AmountAfter is given in Measurement
+type MergedIngredientLink struct {
+	RecipeId          uuid.UUID
+	LinkId            uuid.UUID
+	MeasurementBefore uuid.UUID
+	MeasurementAdded  uuid.UUID
+	Measurement       uuid.UUID
+	AmountBefore      int
+	AmountAdded       int
+	AmountAfter       int
+	RemovedLinkId     uuid.UUID
+}
+
+type IngredientMergeReport struct {
+	KeptId           uuid.UUID
+	DeletedIds       []uuid.UUID
+	RepointedLinks   []uuid.UUID
+	MergedLinks      []*MergedIngredientLink
+	RepointedAliases int
 }
 
 func (Ingredient) TableName() string {
@@ -29,6 +66,10 @@ func (IngredientLink) TableName() string {
 	return "recipeIngredient"
 }
 
+func (IngredientAlias) TableName() string {
+	return "ingredientAlias"
+}
+
 func ToIngredientDB(ingredient *domain.Ingredient) *Ingredient {
 	return &Ingredient{
 		ID:       ingredient.ID,
@@ -48,7 +89,7 @@ func ToIngredientBL(ingredient *Ingredient) *domain.Ingredient {
 }
 
 type IIngredientRepository interface {
-	Create(ctx context.Context, ingredient *domain.Ingredient) error
+	Create(ctx context.Context, ingredient *domain.Ingredient) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.Ingredient, error)
 	GetAll(ctx context.Context, page int) ([]*domain.Ingredient, int, error)
 	GetAllByRecipeId(ctx context.Context, id uuid.UUID) ([]*domain.Ingredient, error)
@@ -56,4 +97,14 @@ type IIngredientRepository interface {
 	Unlink(ctx context.Context, recipeId uuid.UUID, ingredientId uuid.UUID) error
 	Update(ctx context.Context, ingredient *domain.Ingredient) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *DeleteOptions) error
+	UsageCount(ctx context.Context, id uuid.UUID) (int, error)
+	GetUsages(ctx context.Context, id uuid.UUID) ([]*Usage, error)
+	Autocomplete(ctx context.Context, query string, limit int) ([]*domain.Ingredient, error)
+	Search(ctx context.Context, query string, page int) ([]*domain.Ingredient, int, error)
+	AddAlias(ctx context.Context, ingredientId uuid.UUID, alias string) (uuid.UUID, error)
+	RemoveAlias(ctx context.Context, aliasId uuid.UUID) error
+	GetAliases(ctx context.Context, ingredientId uuid.UUID) ([]*IngredientAlias, error)
+	GetByAlias(ctx context.Context, alias string) (*domain.Ingredient, error)
+	Merge(ctx context.Context, keepId uuid.UUID, duplicateIds []uuid.UUID) (*IngredientMergeReport, error)
 }
@@ -33,9 +33,12 @@ func ToIngredientTypeBL(ingredientType *IngredientType) *domain.IngredientType {
 }
 
 type IIngredientTypeRepository interface {
-	Create(ctx context.Context, ingredientType *domain.IngredientType) error
+	Create(ctx context.Context, ingredientType *domain.IngredientType) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.IngredientType, error)
 	GetAll(ctx context.Context) ([]*domain.IngredientType, error)
 	Update(ctx context.Context, measurement *domain.IngredientType) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *DeleteOptions) error
+	UsageCount(ctx context.Context, id uuid.UUID) (int, error)
+	GetUsages(ctx context.Context, id uuid.UUID) ([]*Usage, error)
 }
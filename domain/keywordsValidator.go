@@ -2,8 +2,10 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
+	"io"
 )
 
 type KeyWord struct {
@@ -29,10 +31,98 @@ func ToKeyWordBL(keyWord *KeyWord) *domain.KeyWord {
 	}
 }
 
+const (
+	KeywordCategoryProfanity   = "profanity"
+	KeywordCategorySpam        = "spam"
+	KeywordCategoryAdvertising = "advertising"
+)
+
+const (
+	KeywordSeverityMask = iota + 1
+	KeywordSeverityModerate
+	KeywordSeverityReject
+)
+
+const (
+	KeywordKindLiteral = iota
+	KeywordKindWildcard
+	KeywordKindRegex
+)
+
+const MaxKeywordLength = 128
+
+var KeywordSeverityNames = map[int]string{
+	KeywordSeverityMask:     "mask",
+	KeywordSeverityModerate: "moderate",
+	KeywordSeverityReject:   "reject",
+}
+
+var KeywordKindNames = map[int]string{
+	KeywordKindLiteral:  "literal",
+	KeywordKindWildcard: "wildcard",
+	KeywordKindRegex:    "regex",
+}
+
+var (
+	ErrEmptyKeyword           = errors.New("keyword is empty")
+	ErrKeywordTooLong         = errors.New("keyword is too long")
+	ErrUnknownKeywordCategory = errors.New("unknown keyword category")
+	ErrUnknownKeywordSeverity = errors.New("unknown keyword severity")
+	ErrUnknownKeywordKind     = errors.New("unknown keyword kind")
+	ErrInvalidKeywordPattern  = errors.New("invalid keyword pattern")
+)
+
+type KeywordRule struct {
+	ID       uuid.UUID `gorm:"primaryKey"`
+	Word     string    `gorm:"column:word"`
+	Category string    `gorm:"column:category"`
+	Severity int       `gorm:"column:severity"`
+	Kind     int       `gorm:"column:kind"`
+}
+
+func (KeywordRule) TableName() string {
+	return "word"
+}
+
+const (
+	KeywordSourceSaladName       = "salad.name"
+	KeywordSourceStepDescription = "recipeStep.description"
+	KeywordSourceCommentText     = "comment.text"
+)
+
+type KeywordImportReport struct {
+	Added   int
+	Skipped int
+}
+
+type KeywordMatch struct {
+	WordID   uuid.UUID
+	Word     string
+	Category string
+	Severity int
+	Start    int
+	End      int
+	Fragment string
+}
+
+type KeywordHit struct {
+	Source string
+	ID     uuid.UUID
+	Match  *KeywordMatch
+}
+
 type IKeywordValidatorRepository interface {
-	Create(ctx context.Context, word *domain.KeyWord) error
+	Create(ctx context.Context, word *domain.KeyWord) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.KeyWord, error)
 	GetAll(ctx context.Context) (map[string]uuid.UUID, error)
 	Update(ctx context.Context, word *domain.KeyWord) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	CreateRule(ctx context.Context, rule *KeywordRule) (uuid.UUID, error)
+	UpdateRule(ctx context.Context, rule *KeywordRule) error
+	GetAllRules(ctx context.Context) ([]*KeywordRule, error)
+	Import(ctx context.Context, reader io.Reader) (*KeywordImportReport, error)
+	Export(ctx context.Context, writer io.Writer) error
+	Match(ctx context.Context, text string) ([]*KeywordMatch, error)
+	ScanSalad(ctx context.Context, saladId uuid.UUID) ([]*KeywordHit, error)
+	ScanComment(ctx context.Context, commentId uuid.UUID) ([]*KeywordHit, error)
 }
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// MaxAttemptLoginLength is the size of loginAttempt.login, longer logins are
+// stored cut to it
+const MaxAttemptLoginLength = 64
+
+type LoginAttempt struct {
+	ID        uuid.UUID     `gorm:"primaryKey"`
+	UserID    uuid.NullUUID `gorm:"column:userId"`
+	Login     string        `gorm:"column:login"`
+	IP        string        `gorm:"column:ip"`
+	Success   bool          `gorm:"column:success"`
+	CreatedAt time.Time     `gorm:"column:createdAt"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "loginAttempt"
+}
+
+type Lockout struct {
+	Locked      bool
+	LockedUntil time.Time
+}
+
+type ILoginAttemptRepository interface {
+	Record(ctx context.Context, attempt *LoginAttempt) error
+	CountUserFailures(ctx context.Context, userId uuid.UUID, window time.Duration) (int, error)
+	CountIPFailures(ctx context.Context, ip string, window time.Duration) (int, error)
+	Lock(ctx context.Context, userId uuid.UUID, until time.Time) error
+	Unlock(ctx context.Context, userId uuid.UUID) error
+	GetLockout(ctx context.Context, userId uuid.UUID) (*Lockout, error)
+	Cleanup(ctx context.Context, olderThan time.Time) (int, error)
+}
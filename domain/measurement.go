@@ -38,11 +38,14 @@ func ToMeasurementBL(measurement *Measurement) *domain.Measurement {
 }
 
 type IMeasurementRepository interface {
-	Create(ctx context.Context, measurement *domain.Measurement) error
+	Create(ctx context.Context, measurement *domain.Measurement) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.Measurement, error)
 	GetByRecipeId(ctx context.Context, ingredientId uuid.UUID, recipeId uuid.UUID) (*domain.Measurement, int, error)
 	GetAll(ctx context.Context) ([]*domain.Measurement, error)
 	UpdateLink(ctx context.Context, linkId uuid.UUID, measurementId uuid.UUID, amount int) error
 	Update(ctx context.Context, measurement *domain.Measurement) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *DeleteOptions) error
+	UsageCount(ctx context.Context, id uuid.UUID) (int, error)
+	GetUsages(ctx context.Context, id uuid.UUID) ([]*Usage, error)
 }
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	TokenPurposeEmailVerification = "emailVerification"
+	TokenPurposePasswordReset     = "passwordReset"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenConsumed = errors.New("token already used")
+	ErrTokenEmail    = errors.New("token was issued for another email")
+)
+
+type OneTimeToken struct {
+	ID         uuid.UUID  `gorm:"primaryKey"`
+	UserID     uuid.UUID  `gorm:"column:userId"`
+	Purpose    string     `gorm:"column:purpose"`
+	TokenHash  string     `gorm:"column:tokenHash"`
+	EmailHash  *string    `gorm:"column:emailHash"`
+	CreatedAt  time.Time  `gorm:"column:createdAt"`
+	ExpiresAt  time.Time  `gorm:"column:expiresAt"`
+	ConsumedAt *time.Time `gorm:"column:consumedAt"`
+}
+
+func (OneTimeToken) TableName() string {
+	return "oneTimeToken"
+}
+
+type IOneTimeTokenRepository interface {
+	Create(ctx context.Context, token *OneTimeToken, plaintext string) (uuid.UUID, error)
+	Consume(ctx context.Context, plaintext string, purpose string) (*OneTimeToken, error)
+	VerifyEmail(ctx context.Context, plaintext string) (uuid.UUID, error)
+	PurgeExpired(ctx context.Context) (int, error)
+}
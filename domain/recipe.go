@@ -15,10 +15,57 @@ type Recipe struct {
 	Rating           float32   `gorm:"column:rating"`
 }
 
+const (
+	DifficultyUnknown = 0
+	DifficultyEasy    = 1
+	DifficultyMedium  = 2
+	DifficultyHard    = 3
+)
+
+type Equipment struct {
+	ID   uuid.UUID `gorm:"primaryKey"`
+	Name string    `gorm:"column:name"`
+}
+
+type EquipmentLink struct {
+	ID          uuid.UUID `gorm:"primaryKey"`
+	RecipeId    uuid.UUID `gorm:"column:recipeId"`
+	EquipmentId uuid.UUID `gorm:"column:equipmentId"`
+}
+
+type RecipeDetails struct {
+	ID           uuid.UUID    `gorm:"primaryKey"`
+	PrepMinutes  int          `gorm:"column:prepMinutes"`
+	CookMinutes  int          `gorm:"column:cookMinutes"`
+	RestMinutes  int          `gorm:"column:restMinutes"`
+	TotalMinutes int          `gorm:"column:totalMinutes;->"`
+	Difficulty   int          `gorm:"column:difficulty"`
+	Equipment    []*Equipment `gorm:"-"`
+}
+
+type RecipeListFilter struct {
+	domain.RecipeFilter
+	MaxTotalMinutes   int
+	Difficulties      []int
+	ExcludedEquipment []uuid.UUID
+}
+
 func (Recipe) TableName() string {
 	return "recipe"
 }
 
+func (Equipment) TableName() string {
+	return "equipment"
+}
+
+func (EquipmentLink) TableName() string {
+	return "recipeEquipment"
+}
+
+func (RecipeDetails) TableName() string {
+	return "recipe"
+}
+
 func ToRecipeDB(recipe *domain.Recipe) *Recipe {
 	return &Recipe{
 		ID:               recipe.ID,
@@ -48,4 +95,8 @@ type IRecipeRepository interface {
 	GetAll(ctx context.Context, filter *domain.RecipeFilter, page int) ([]*domain.Recipe, error)
 	Update(ctx context.Context, recipe *domain.Recipe) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	GetDetails(ctx context.Context, recipeId uuid.UUID) (*RecipeDetails, error)
+	UpdateDetails(ctx context.Context, details *RecipeDetails) error
+	GetAllEquipment(ctx context.Context) ([]*Equipment, error)
+	GetAllFiltered(ctx context.Context, filter *RecipeListFilter, page int) ([]*domain.Recipe, int, error)
 }
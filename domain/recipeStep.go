@@ -2,8 +2,16 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrStepOutOfRange    = errors.New("step num out of range")
+	ErrInvalidStepOrder  = errors.New("step order does not match recipe steps")
+	ErrForeignRecipeLink = errors.New("ingredient link belongs to another recipe")
 )
 
 type RecipeStep struct {
@@ -12,12 +20,50 @@ type RecipeStep struct {
 	Name        string    `gorm:"column:name"`
 	Description string    `gorm:"column:description"`
 	StepNum     int       `gorm:"column:stepNum"`
+	Section     string    `gorm:"column:section"`
+	Duration    int       `gorm:"column:duration"`
+	TimerLabel  string    `gorm:"column:timerLabel"`
+}
+
+type StepIngredientLink struct {
+	ID     uuid.UUID `gorm:"primaryKey"`
+	StepId uuid.UUID `gorm:"column:stepId"`
+	LinkId uuid.UUID `gorm:"column:linkId"`
+}
+
+type RecipeStepDetails struct {
+	Section    string
+	Duration   time.Duration
+	TimerLabel string
+}
+
+type StepIngredient struct {
+	LinkId       uuid.UUID `gorm:"column:linkId"`
+	IngredientId uuid.UUID `gorm:"column:ingredientId"`
+	Name         string    `gorm:"column:name"`
+	Measurement  uuid.UUID `gorm:"column:measurement"`
+	Amount       int       `gorm:"column:amount"`
+}
+
+type SectionStep struct {
+	Step        *domain.RecipeStep
+	Details     RecipeStepDetails
+	Ingredients []*StepIngredient
+}
+
+type RecipeSection struct {
+	Name  string
+	Steps []*SectionStep
 }
 
 func (RecipeStep) TableName() string {
 	return "recipeStep"
 }
 
+func (StepIngredientLink) TableName() string {
+	return "stepIngredient"
+}
+
 func ToStepDB(step *domain.RecipeStep) *RecipeStep {
 	return &RecipeStep{
 		ID:          step.ID,
@@ -39,10 +85,16 @@ func ToStepBL(step *RecipeStep) *domain.RecipeStep {
 }
 
 type IRecipeStepRepository interface {
-	Create(ctx context.Context, recipeStep *domain.RecipeStep) error
+	Create(ctx context.Context, recipeStep *domain.RecipeStep) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.RecipeStep, error)
 	GetAllByRecipeID(ctx context.Context, recipeId uuid.UUID) ([]*domain.RecipeStep, error)
 	Update(ctx context.Context, recipeStep *domain.RecipeStep) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
 	DeleteAllByRecipeID(ctx context.Context, recipeId uuid.UUID) error
+	Reorder(ctx context.Context, recipeId uuid.UUID, orderedStepIds []uuid.UUID) error
+	InsertAt(ctx context.Context, recipeStep *domain.RecipeStep, position int) (uuid.UUID, error)
+	UpdateDetails(ctx context.Context, stepId uuid.UUID, details *RecipeStepDetails) error
+	LinkIngredient(ctx context.Context, stepId uuid.UUID, linkId uuid.UUID) error
+	UnlinkIngredient(ctx context.Context, stepId uuid.UUID, linkId uuid.UUID) error
+	GetSections(ctx context.Context, recipeId uuid.UUID) ([]*RecipeSection, error)
 }
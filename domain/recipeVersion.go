@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ErrVersionReferencesDeleted is returned by Revert when the version links to
+// an ingredient, measurement, equipment or salad type deleted since then
+var ErrVersionReferencesDeleted = errors.New("recipe version references deleted rows")
+
+type SaladSnapshot struct {
+	AuthorID    uuid.UUID `json:"authorId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+type RecipeFieldsSnapshot struct {
+	Status           int         `json:"status"`
+	NumberOfServings int         `json:"numberOfServings"`
+	TimeToCook       int         `json:"timeToCook"`
+	PrepMinutes      int         `json:"prepMinutes"`
+	CookMinutes      int         `json:"cookMinutes"`
+	RestMinutes      int         `json:"restMinutes"`
+	Difficulty       int         `json:"difficulty"`
+	Equipment        []uuid.UUID `json:"equipment"`
+}
+
+type StepSnapshot struct {
+	ID          uuid.UUID   `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	StepNum     int         `json:"stepNum"`
+	Section     string      `json:"section"`
+	Duration    int         `json:"duration"`
+	TimerLabel  string      `json:"timerLabel"`
+	LinkIds     []uuid.UUID `json:"linkIds"`
+}
+
+type IngredientLinkSnapshot struct {
+	ID           uuid.UUID `json:"id"`
+	IngredientId uuid.UUID `json:"ingredientId"`
+	Measurement  uuid.UUID `json:"measurement"`
+	Amount       int       `json:"amount"`
+}
+
+type RecipeSnapshot struct {
+	Salad       SaladSnapshot             `json:"salad"`
+	Recipe      RecipeFieldsSnapshot      `json:"recipe"`
+	Steps       []*StepSnapshot           `json:"steps"`
+	Ingredients []*IngredientLinkSnapshot `json:"ingredients"`
+	Types       []uuid.UUID               `json:"types"`
+}
+
+type RecipeVersion struct {
+	ID        uuid.UUID       `gorm:"primaryKey"`
+	RecipeID  uuid.UUID       `gorm:"column:recipeId"`
+	Version   int             `gorm:"column:version"`
+	CreatedAt time.Time       `gorm:"column:createdAt"`
+	Snapshot  *RecipeSnapshot `gorm:"column:snapshot;serializer:json"`
+}
+
+func (RecipeVersion) TableName() string {
+	return "recipeVersion"
+}
+
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+type StepChange struct {
+	Old *StepSnapshot
+	New *StepSnapshot
+}
+
+type IngredientLinkChange struct {
+	Old *IngredientLinkSnapshot
+	New *IngredientLinkSnapshot
+}
+
+type RecipeDiff struct {
+	From               int
+	To                 int
+	Fields             []*FieldChange
+	AddedSteps         []*StepSnapshot
+	RemovedSteps       []*StepSnapshot
+	ChangedSteps       []*StepChange
+	AddedIngredients   []*IngredientLinkSnapshot
+	RemovedIngredients []*IngredientLinkSnapshot
+	ChangedIngredients []*IngredientLinkChange
+	AddedTypes         []uuid.UUID
+	RemovedTypes       []uuid.UUID
+}
+
+type IRecipeVersionRepository interface {
+	GetAll(ctx context.Context, recipeId uuid.UUID) ([]*RecipeVersion, error)
+	GetByVersion(ctx context.Context, recipeId uuid.UUID, version int) (*RecipeVersion, error)
+	Diff(ctx context.Context, recipeId uuid.UUID, from int, to int) (*RecipeDiff, error)
+	Revert(ctx context.Context, recipeId uuid.UUID, version int) (int, error)
+}
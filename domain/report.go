@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ReportTargetSalad      = "salad"
+	ReportTargetRecipeStep = "recipeStep"
+	ReportTargetComment    = "comment"
+)
+
+const (
+	ReportReasonSpam = iota + 1
+	ReportReasonOffensive
+	ReportReasonInappropriate
+	ReportReasonCopyright
+	ReportReasonOther
+)
+
+const (
+	ReportStatusOpen = iota
+	ReportStatusResolved
+)
+
+const (
+	ReportActionDismiss = iota + 1
+	ReportActionHide
+	ReportActionDelete
+	ReportActionWarnAuthor
+)
+
+var (
+	ErrDuplicateReport     = errors.New("target is already reported by this user")
+	ErrUnknownReportTarget = errors.New("unknown report target kind")
+	ErrUnknownReportReason = errors.New("unknown report reason")
+	ErrUnknownReportAction = errors.New("unknown report action")
+)
+
+type Report struct {
+	ID          uuid.UUID     `gorm:"primaryKey"`
+	ReporterID  uuid.UUID     `gorm:"column:reporterId"`
+	TargetKind  string        `gorm:"column:targetKind"`
+	TargetID    uuid.UUID     `gorm:"column:targetId"`
+	Reason      int           `gorm:"column:reason"`
+	Details     string        `gorm:"column:details"`
+	CreatedAt   time.Time     `gorm:"column:createdAt"`
+	Status      int           `gorm:"column:status"`
+	Action      *int          `gorm:"column:action"`
+	ModeratorID uuid.NullUUID `gorm:"column:moderatorId"`
+	ResolvedAt  *time.Time    `gorm:"column:resolvedAt"`
+}
+
+func (Report) TableName() string {
+	return "report"
+}
+
+type ReportSummary struct {
+	TargetKind    string      `gorm:"column:targetKind"`
+	TargetID      uuid.UUID   `gorm:"column:targetId"`
+	Count         int         `gorm:"column:count"`
+	FirstReportAt time.Time   `gorm:"column:firstReportAt"`
+	LastReportAt  time.Time   `gorm:"column:lastReportAt"`
+	Reasons       map[int]int `gorm:"-"`
+}
+
+type IReportRepository interface {
+	Create(ctx context.Context, report *Report) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*Report, error)
+	GetByTarget(ctx context.Context, targetKind string, targetId uuid.UUID) ([]*Report, error)
+	GetSummary(ctx context.Context, targetKind string, targetId uuid.UUID) (*ReportSummary, error)
+	GetOpen(ctx context.Context, page int) ([]*ReportSummary, int, error)
+	Resolve(ctx context.Context, targetKind string, targetId uuid.UUID, action int, moderatorId uuid.UUID) (int, error)
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+const (
+	RoleUser      = DefaultRole
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	PermissionCreateRecipe    = "recipe.create"
+	PermissionPublishRecipe   = "recipe.publish"
+	PermissionCreateComment   = "comment.create"
+	PermissionModerateComment = "comment.moderate"
+	PermissionResolveReport   = "report.resolve"
+	PermissionEditKeywords    = "keyword.edit"
+	PermissionEditCatalog     = "catalog.edit"
+	PermissionManageUsers     = "user.manage"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+type Role struct {
+	ID   uuid.UUID `gorm:"primaryKey"`
+	Name string    `gorm:"column:name"`
+}
+
+func (Role) TableName() string {
+	return "role"
+}
+
+type Permission struct {
+	ID          uuid.UUID `gorm:"primaryKey"`
+	Name        string    `gorm:"column:name"`
+	Description string    `gorm:"column:description"`
+}
+
+func (Permission) TableName() string {
+	return "permission"
+}
+
+type UserRole struct {
+	UserID uuid.UUID `gorm:"column:userId;primaryKey"`
+	RoleID uuid.UUID `gorm:"column:roleId;primaryKey"`
+}
+
+func (UserRole) TableName() string {
+	return "userRole"
+}
+
+type IRoleRepository interface {
+	GetAll(ctx context.Context) ([]*Role, error)
+	GetRolePermissions(ctx context.Context, roleName string) ([]*Permission, error)
+	Assign(ctx context.Context, userId uuid.UUID, roleNames ...string) error
+	Revoke(ctx context.Context, userId uuid.UUID, roleName string) error
+	GetUserRoles(ctx context.Context, userId uuid.UUID) ([]*Role, error)
+	GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]string, error)
+	HasPermission(ctx context.Context, userId uuid.UUID, permission string) (bool, error)
+}
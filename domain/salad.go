@@ -43,4 +43,8 @@ type ISaladRepository interface {
 	GetAllRatedByUser(ctx context.Context, userId uuid.UUID, page int) ([]*domain.Salad, int, error)
 	Update(ctx context.Context, salad *domain.Salad) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	Clone(ctx context.Context, saladId uuid.UUID, newAuthorId uuid.UUID) (uuid.UUID, error)
+	GetParent(ctx context.Context, saladId uuid.UUID) (*domain.Salad, error)
+	GetForks(ctx context.Context, saladId uuid.UUID, page int) ([]*domain.Salad, int, error)
+	GetAllFiltered(ctx context.Context, filter *RecipeListFilter, page int) ([]*domain.Salad, int, error)
 }
@@ -43,7 +43,7 @@ func ToSaladTypeBL(saladType *SaladType) *domain.SaladType {
 }
 
 type ISaladTypeRepository interface {
-	Create(ctx context.Context, saladType *domain.SaladType) error
+	Create(ctx context.Context, saladType *domain.SaladType) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.SaladType, error)
 	GetAll(ctx context.Context, page int) ([]*domain.SaladType, int, error)
 	GetAllBySaladId(ctx context.Context, saladId uuid.UUID) ([]*domain.SaladType, error)
@@ -51,4 +51,7 @@ type ISaladTypeRepository interface {
 	Link(ctx context.Context, saladId uuid.UUID, saladTypeId uuid.UUID) error
 	Unlink(ctx context.Context, saladId uuid.UUID, saladTypeId uuid.UUID) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *DeleteOptions) error
+	UsageCount(ctx context.Context, id uuid.UUID) (int, error)
+	GetUsages(ctx context.Context, id uuid.UUID) ([]*Usage, error)
 }
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionExpired     = errors.New("session expired")
+	ErrSessionRevoked     = errors.New("session revoked")
+	ErrRefreshTokenReused = errors.New("refresh token reused, session family revoked")
+)
+
+type Session struct {
+	ID          uuid.UUID     `gorm:"primaryKey"`
+	UserID      uuid.UUID     `gorm:"column:userId"`
+	FamilyID    uuid.UUID     `gorm:"column:familyId"`
+	RefreshHash string        `gorm:"column:refreshHash"`
+	Device      string        `gorm:"column:device"`
+	UserAgent   string        `gorm:"column:userAgent"`
+	CreatedAt   time.Time     `gorm:"column:createdAt"`
+	ExpiresAt   time.Time     `gorm:"column:expiresAt"`
+	RevokedAt   *time.Time    `gorm:"column:revokedAt"`
+	ReplacedBy  uuid.NullUUID `gorm:"column:replacedBy"`
+}
+
+func (Session) TableName() string {
+	return "session"
+}
+
+type ISessionRepository interface {
+	Create(ctx context.Context, session *Session, refreshToken string) (uuid.UUID, error)
+	GetByRefreshToken(ctx context.Context, refreshToken string) (*Session, error)
+	Rotate(ctx context.Context, oldToken string, newToken string, expiresAt time.Time) (*Session, error)
+	Revoke(ctx context.Context, sessionId uuid.UUID) error
+	RevokeAll(ctx context.Context, userId uuid.UUID) (int, error)
+	GetActive(ctx context.Context, userId uuid.UUID) ([]*Session, error)
+	PurgeExpired(ctx context.Context) (int, error)
+}
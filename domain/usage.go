@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	UsageKindRecipe     = "recipe"
+	UsageKindSalad      = "salad"
+	UsageKindIngredient = "ingredient"
+)
+
+var ErrReplaceWithItself = errors.New("row cannot be replaced with itself")
+
+type Usage struct {
+	Kind string    `gorm:"column:kind"`
+	ID   uuid.UUID `gorm:"column:id"`
+	Name string    `gorm:"column:name"`
+}
+
+type DeleteOptions struct {
+	ReplacementId uuid.UUID
+}
+
+type ErrInUse struct {
+	ID     uuid.UUID
+	Usages []*Usage
+}
+
+func (e *ErrInUse) Error() string {
+	return fmt.Sprintf("%s is still referenced by %d rows", e.ID, len(e.Usages))
+}
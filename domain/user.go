@@ -43,9 +43,11 @@ func ToUserBL(user *User) *domain.User {
 }
 
 type IUserRepository interface {
-	Create(ctx context.Context, user *domain.User) error
+	Create(ctx context.Context, user *domain.User) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	GetByUsername(ctx context.Context, username string) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error)
 	GetAll(ctx context.Context, page int) ([]*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const apiKeySecretBytes = 32
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewApiKeyRepository(db *gorm.DB) rDomain.IApiKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *rDomain.ApiKey) (*rDomain.CreatedApiKey, error) {
+	secret := make([]byte, apiKeySecretBytes)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, fmt.Errorf("creating api key: %w", err)
+	}
+	plaintext := rDomain.ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	dbKey := *key
+	dbKey.ID = idOrNew(key.ID)
+	dbKey.Prefix = plaintext[:rDomain.ApiKeyPrefixLength]
+	dbKey.KeyHash = hashToken(plaintext)
+	dbKey.CreatedAt = time.Now()
+	dbKey.LastUsedAt = nil
+	dbKey.RevokedAt = nil
+	if dbKey.Scopes == nil {
+		dbKey.Scopes = make([]string, 0)
+	}
+
+	err = createWithId(r.db.WithContext(ctx), &dbKey, dbKey.ID)
+	if err != nil {
+		return nil, fmt.Errorf("creating api key: %w", err)
+	}
+	return &rDomain.CreatedApiKey{
+		Key:       &dbKey,
+		Plaintext: plaintext,
+	}, nil
+}
+
+// GetByKey finds keys by their public prefix and compares hashes in constant
+// time, so response timing does not tell how much of a guessed key matched.
+// A successful lookup records the time of use. The hash is left out of the
+// result, like in GetByUser
+func (r *apiKeyRepository) GetByKey(ctx context.Context, plaintext string) (*rDomain.ApiKey, error) {
+	if !strings.HasPrefix(plaintext, rDomain.ApiKeyPrefix) || len(plaintext) < rDomain.ApiKeyPrefixLength {
+		return nil, fmt.Errorf("getting api key: %w", rDomain.ErrApiKeyNotFound)
+	}
+
+	var candidates []*rDomain.ApiKey
+	err := r.db.WithContext(ctx).
+		Where("prefix = ?", plaintext[:rDomain.ApiKeyPrefixLength]).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting api key: %w", err)
+	}
+
+	hash := []byte(hashToken(plaintext))
+	var key *rDomain.ApiKey
+	for _, candidate := range candidates {
+		if subtle.ConstantTimeCompare(hash, []byte(candidate.KeyHash)) == 1 {
+			key = candidate
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("getting api key: %w", rDomain.ErrApiKeyNotFound)
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("getting api key: %w", rDomain.ErrApiKeyRevoked)
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("getting api key: %w", rDomain.ErrApiKeyExpired)
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(key).
+		Update("lastUsedAt", now).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting api key (last use): %w", err)
+	}
+	key.LastUsedAt = &now
+	key.KeyHash = ""
+	return key, nil
+}
+
+// GetByUser lists the keys of the user for display, their hashes are left out
+func (r *apiKeyRepository) GetByUser(ctx context.Context, userId uuid.UUID) ([]*rDomain.ApiKey, error) {
+	var keys []*rDomain.ApiKey
+	err := r.db.WithContext(ctx).
+		Where("userId = ?", userId).
+		Order("createdAt desc").
+		Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting api keys by user: %w", err)
+	}
+	for _, key := range keys {
+		key.KeyHash = ""
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error {
+	res := r.db.WithContext(ctx).
+		Model(&rDomain.ApiKey{}).
+		Where("id = ?", keyId).
+		Where("userId = ?", userId).
+		Where("revokedAt is null").
+		Update("revokedAt", time.Now())
+	if res.Error != nil {
+		return fmt.Errorf("revoking api key: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		err := r.db.WithContext(ctx).
+			Model(&rDomain.ApiKey{}).
+			Where("id = ?", keyId).
+			Where("userId = ?", userId).
+			Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("revoking api key: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("revoking api key: %w", rDomain.ErrApiKeyNotFound)
+		}
+	}
+	return nil
+}
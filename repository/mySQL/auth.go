@@ -2,11 +2,15 @@ package mysql
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
 )
 
 type authRepository struct {
@@ -21,31 +25,204 @@ func NewAuthRepository(db *gorm.DB) rDomain.IAuthRepository {
 
 func (r *authRepository) Register(ctx context.Context, authInfo *domain.User) (uuid.UUID, error) {
 	dbUser := rDomain.ToUserDB(authInfo)
-	dbUser.ID = uuid.New()
-	res := r.db.WithContext(ctx).
-		Create(&dbUser)
-	err := res.Error
+	dbUser.ID = idOrNew(authInfo.ID)
+	if dbUser.Role == "" {
+		dbUser.Role = rDomain.DefaultRole
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := createWithId(tx, &dbUser, dbUser.ID)
+		if err != nil {
+			return err
+		}
+		err = setPasswordHashInfo(tx, dbUser.ID, dbUser.Password)
+		if err != nil {
+			return err
+		}
+		return setUserRole(tx, dbUser.ID, dbUser.Role)
+	})
 
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("user registration: %w", err)
 	}
-	return authInfo.ID, nil
+	return dbUser.ID, nil
+}
+
+func (r *authRepository) GetByUsername(ctx context.Context, username string) (*rDomain.UserAuth, error) {
+	data, err := r.getAuth(ctx, func(query *gorm.DB) *gorm.DB {
+		return query.Where("login = ?", username)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting user by username: %w", err)
+	}
+	return data, nil
 }
 
-func (r *authRepository) GetByUsername(ctx context.Context, username string) (*domain.UserAuth, error) {
-	//var dbUser domain.UserAuth
-	var dbU rDomain.User
+func (r *authRepository) GetByEmail(ctx context.Context, email string) (*rDomain.UserAuth, error) {
+	data, err := r.getAuth(ctx, func(query *gorm.DB) *gorm.DB {
+		return query.Where("email = ?", email)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting user by email: %w", err)
+	}
+	return data, nil
+}
 
+// GetByLogin matches the login or the email ignoring case and surrounding
+// spaces, so "Alice" finds the account registered as "alice". An exact match
+// wins, so accounts left out of the normalised columns by a collision can
+// still sign in, then a login match wins over an email match
+func (r *authRepository) GetByLogin(ctx context.Context, usernameOrEmail string) (*rDomain.UserAuth, error) {
+	normalized := strings.ToLower(strings.TrimSpace(usernameOrEmail))
+	// every term is served by a unique index. login and email compare ignoring
+	// case, so the exact match is picked from the candidates by loginMatchRank
+	var candidates []*userAuthRow
 	err := r.db.WithContext(ctx).
-		Where("login = ?", username).
+		Where("loginNormalized = ? or emailNormalized = ? or login = ? or email = ?",
+			normalized, normalized, usernameOrEmail, usernameOrEmail).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting user by login: %w", err)
+	}
+
+	var best *userAuthRow
+	bestRank := 0
+	for _, candidate := range candidates {
+		rank := loginMatchRank(candidate, usernameOrEmail, normalized)
+		if rank > bestRank {
+			best, bestRank = candidate, rank
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("getting user by login: %w", gorm.ErrRecordNotFound)
+	}
+	return best.toUserAuth(), nil
+}
+
+// loginMatchRank orders the ways an account can match a login, 0 means a
+// match only by collation, which GetByLogin does not accept
+func loginMatchRank(user *userAuthRow, login string, normalized string) int {
+	switch {
+	case user.Username == login:
+		return 4
+	case user.Email == login:
+		return 3
+	case user.LoginNormalized != nil && *user.LoginNormalized == normalized:
+		return 2
+	case user.EmailNormalized != nil && *user.EmailNormalized == normalized:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type userAuthRow struct {
+	rDomain.User
+	LoginNormalized   *string                    `gorm:"column:loginNormalized"`
+	EmailNormalized   *string                    `gorm:"column:emailNormalized"`
+	PasswordAlgorithm string                     `gorm:"column:passwordAlgorithm"`
+	PasswordParams    rDomain.PasswordHashParams `gorm:"column:passwordParams;serializer:json"`
+}
+
+func (u *userAuthRow) toUserAuth() *rDomain.UserAuth {
+	data := new(rDomain.UserAuth)
+	data.ID = u.ID
+	data.HashedPass = u.Password
+	data.Role = u.Role
+	data.HashAlgorithm = u.PasswordAlgorithm
+	data.HashParams = u.PasswordParams
+	return data
+}
+
+func (r *authRepository) getAuth(ctx context.Context, scope func(query *gorm.DB) *gorm.DB) (*rDomain.UserAuth, error) {
+	var dbU userAuthRow
+	err := r.db.WithContext(ctx).
+		Scopes(scope).
 		First(&dbU).Error
 	if err != nil {
-		return nil, fmt.Errorf("getting user by username: %w", err)
+		return nil, err
 	}
+	return dbU.toUserAuth(), nil
+}
 
-	data := new(domain.UserAuth)
-	data.ID = dbU.ID
-	data.HashedPass = dbU.Password
-	data.Role = dbU.Role
-	return data, nil
+// UpdatePasswordHash replaces the hash only if it still equals oldHash, so a
+// rehash on login cannot overwrite a password changed in the meantime
+func (r *authRepository) UpdatePasswordHash(ctx context.Context, userId uuid.UUID, oldHash string,
+	newHash *rDomain.PasswordHash) error {
+	params, err := json.Marshal(newHash.Params)
+	if err != nil {
+		return fmt.Errorf("updating password hash: %w", err)
+	}
+
+	res := r.db.WithContext(ctx).
+		Model(&rDomain.User{}).
+		Where("id = ?", userId).
+		Where("cast(password as binary) = ?", oldHash).
+		Updates(map[string]interface{}{
+			"password":          newHash.Hash,
+			"passwordAlgorithm": newHash.Algorithm,
+			"passwordParams":    string(params),
+		})
+	if res.Error != nil {
+		return fmt.Errorf("updating password hash: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		var hashes []string
+		err = r.db.WithContext(ctx).
+			Model(&rDomain.User{}).
+			Where("id = ?", userId).
+			Pluck("password", &hashes).Error
+		if err != nil {
+			return fmt.Errorf("updating password hash: %w", err)
+		}
+		if len(hashes) == 0 {
+			return fmt.Errorf("updating password hash: %w", gorm.ErrRecordNotFound)
+		}
+		if hashes[0] != oldHash {
+			return fmt.Errorf("updating password hash: %w", rDomain.ErrPasswordHashChanged)
+		}
+	}
+	return nil
+}
+
+// passwordHashInfo tells the algorithm and its parameters from a stored hash,
+// the same way migration 000017 does for the users that existed before it
+func passwordHashInfo(hash string) (string, rDomain.PasswordHashParams) {
+	var params rDomain.PasswordHashParams
+	if len(hash) >= 7 && strings.HasPrefix(hash, "$2") && hash[3] == '$' && hash[6] == '$' {
+		cost, err := strconv.Atoi(hash[4:6])
+		if err == nil {
+			params.Cost = cost
+			return rDomain.PasswordAlgorithmBcrypt, params
+		}
+	}
+
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$key
+	parts := strings.Split(hash, "$")
+	if len(parts) == 6 && parts[1] == rDomain.PasswordAlgorithmArgon2id {
+		_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+		if err != nil {
+			params = rDomain.PasswordHashParams{}
+		} else {
+			params.SaltLength = uint32(base64.RawStdEncoding.DecodedLen(len(parts[4])))
+			params.KeyLength = uint32(base64.RawStdEncoding.DecodedLen(len(parts[5])))
+		}
+		return rDomain.PasswordAlgorithmArgon2id, params
+	}
+	return rDomain.PasswordAlgorithmUnknown, params
+}
+
+// setPasswordHashInfo stores the algorithm and parameters of the hash a user
+// was written with, the user model itself has no columns for them
+func setPasswordHashInfo(tx *gorm.DB, userId uuid.UUID, hash string) error {
+	algorithm, params := passwordHashInfo(hash)
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return tx.Model(&rDomain.User{}).
+		Where("id = ?", userId).
+		Updates(map[string]interface{}{
+			"passwordAlgorithm": algorithm,
+			"passwordParams":    string(encoded),
+		}).Error
 }
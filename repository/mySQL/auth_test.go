@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_passwordHashInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		hash      string
+		algorithm string
+		params    rDomain.PasswordHashParams
+	}{
+		{
+			name:      "bcrypt",
+			hash:      "$2a$12$R9h/cIPz0gi.URNNX3kh2OPST9/PgBkqquzi.Ss7KIUgO2t0jWMUW",
+			algorithm: rDomain.PasswordAlgorithmBcrypt,
+			params:    rDomain.PasswordHashParams{Cost: 12},
+		}, // bcrypt
+		{
+			name:      "argon2id",
+			hash:      "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+			algorithm: rDomain.PasswordAlgorithmArgon2id,
+			params: rDomain.PasswordHashParams{
+				Memory:      65536,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+		}, // argon2id
+		{
+			name:      "argon2id без параметров",
+			hash:      "$argon2id$v=19$broken$c2FsdA$aGFzaA",
+			algorithm: rDomain.PasswordAlgorithmArgon2id,
+		}, // argon2id без параметров
+		{
+			name:      "неизвестный формат",
+			hash:      "pass",
+			algorithm: rDomain.PasswordAlgorithmUnknown,
+		}, // неизвестный формат
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, params := passwordHashInfo(tt.hash)
+
+			require.Equal(t, tt.algorithm, algorithm)
+			require.Equal(t, tt.params, params)
+		})
+	}
+}
@@ -2,11 +2,14 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type commentRepository struct {
@@ -19,15 +22,14 @@ func NewCommentRepository(db *gorm.DB) rDomain.ICommentRepository {
 	}
 }
 
-func (r *commentRepository) Create(ctx context.Context, comment *domain.Comment) error {
+func (r *commentRepository) Create(ctx context.Context, comment *domain.Comment) (uuid.UUID, error) {
 	dbComment := rDomain.ToCommentDB(comment)
-	dbComment.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbComment).Error
+	dbComment.ID = idOrNew(comment.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbComment, dbComment.ID)
 	if err != nil {
-		return fmt.Errorf("creating comment: %w", err)
+		return uuid.Nil, fmt.Errorf("creating comment: %w", err)
 	}
-	return nil
+	return dbComment.ID, nil
 }
 
 func (r *commentRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.Comment, error) {
@@ -57,6 +59,7 @@ func (r *commentRepository) GetAllBySaladID(ctx context.Context, saladId uuid.UU
 	var comments []*rDomain.Comment
 	err := r.db.WithContext(ctx).
 		Where("salad = ?", saladId).
+		Where("moderation = ?", rDomain.CommentVisible).
 		Limit(PageSize).
 		Offset(PageSize * (page - 1)).
 		Find(&comments).Error
@@ -69,9 +72,15 @@ func (r *commentRepository) GetAllBySaladID(ctx context.Context, saladId uuid.UU
 		resComments = append(resComments, rDomain.ToCommentBL(comment))
 	}
 
-	var tmp []*rDomain.Comment
-	count := r.db.WithContext(ctx).
-		Find(&tmp).RowsAffected
+	var count int64
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Comment{}).
+		Where("salad = ?", saladId).
+		Where("moderation = ?", rDomain.CommentVisible).
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting comments by salad id: %w", err)
+	}
 	numPages := count / PageSize
 	if count%PageSize != 0 {
 		numPages++
@@ -82,8 +91,28 @@ func (r *commentRepository) GetAllBySaladID(ctx context.Context, saladId uuid.UU
 
 func (r *commentRepository) Update(ctx context.Context, comment *domain.Comment) error {
 	dbComment := rDomain.ToCommentDB(comment)
-	err := r.db.WithContext(ctx).
-		Save(&dbComment).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old rDomain.Comment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&old, dbComment.ID).Error
+		if err != nil {
+			return err
+		}
+		if old.Text != dbComment.Text || old.Rating != dbComment.Rating {
+			revision := rDomain.CommentRevision{
+				ID:        uuid.New(),
+				CommentID: old.ID,
+				Text:      old.Text,
+				Rating:    old.Rating,
+				EditedAt:  time.Now(),
+			}
+			err = tx.Create(&revision).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Save(&dbComment).Error
+	})
 	if err != nil {
 		return fmt.Errorf("updating comment: %w", err)
 	}
@@ -98,3 +127,372 @@ func (r *commentRepository) DeleteById(ctx context.Context, id uuid.UUID) error
 	}
 	return nil
 }
+
+func (r *commentRepository) Reply(ctx context.Context, reply *rDomain.CommentReply) (uuid.UUID, error) {
+	dbReply := *reply
+	dbReply.ID = idOrNew(reply.ID)
+	dbReply.Depth = 1
+	dbReply.CreatedAt = time.Now()
+	// like a new comment, a new reply is visible until moderated
+	dbReply.Moderation = rDomain.CommentVisible
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Select("id").
+			First(&rDomain.Comment{}, dbReply.CommentID).Error
+		if err != nil {
+			return fmt.Errorf("getting comment: %w", err)
+		}
+		if dbReply.ParentID.Valid {
+			var parent rDomain.CommentReply
+			err = tx.First(&parent, dbReply.ParentID.UUID).Error
+			if err != nil {
+				return fmt.Errorf("getting parent reply: %w", err)
+			}
+			if parent.CommentID != dbReply.CommentID {
+				return rDomain.ErrForeignReplyRoot
+			}
+			if parent.Depth >= rDomain.MaxReplyDepth {
+				return rDomain.ErrReplyTooDeep
+			}
+			dbReply.Depth = parent.Depth + 1
+		}
+		return createWithId(tx, &dbReply, dbReply.ID)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("replying to comment: %w", err)
+	}
+	return dbReply.ID, nil
+}
+
+// GetThreads lists the comments of the salad with their replies. Moderation
+// applies to comments and replies alike, see CommentListOptions; the replies
+// below a reply left out are left out as well
+func (r *commentRepository) GetThreads(ctx context.Context, saladId uuid.UUID,
+	opts *rDomain.CommentListOptions, page int) ([]*rDomain.CommentThread, int, error) {
+	if opts == nil {
+		opts = &rDomain.CommentListOptions{}
+	}
+	query := func() *gorm.DB {
+		return r.db.WithContext(ctx).
+			Model(&rDomain.Comment{}).
+			Where("salad = ?", saladId).
+			Scopes(moderationScope(opts))
+	}
+
+	type moderatedComment struct {
+		rDomain.Comment
+		Moderation int `gorm:"column:moderation"`
+	}
+	var comments []*moderatedComment
+	err := query().
+		Select("comment.*").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Scan(&comments).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting comment threads: %w", err)
+	}
+	var count int64
+	err = query().
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting comment threads: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	threads := make([]*rDomain.CommentThread, 0, len(comments))
+	if len(comments) == 0 {
+		return threads, int(numPages), nil
+	}
+	commentIds := make([]uuid.UUID, 0, len(comments))
+	for _, comment := range comments {
+		commentIds = append(commentIds, comment.ID)
+	}
+
+	var replies []*rDomain.CommentReply
+	err = r.db.WithContext(ctx).
+		Where("commentId in ?", commentIds).
+		Scopes(moderationScope(opts)).
+		Order("depth").
+		Order("createdAt").
+		Find(&replies).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting comment threads (replies): %w", err)
+	}
+
+	roots := make(map[uuid.UUID]*rDomain.CommentThread, len(comments))
+	for _, comment := range comments {
+		thread := &rDomain.CommentThread{
+			Comment:    rDomain.ToCommentBL(&comment.Comment),
+			Moderation: comment.Moderation,
+			Replies:    make([]*rDomain.ReplyNode, 0),
+		}
+		roots[comment.ID] = thread
+		threads = append(threads, thread)
+	}
+	nodes := make(map[uuid.UUID]*rDomain.ReplyNode, len(replies))
+	for _, reply := range replies {
+		thread := roots[reply.CommentID]
+		parent, ok := nodes[reply.ParentID.UUID]
+		if reply.ParentID.Valid && !ok {
+			// replies come ordered by depth, so a missing parent was left out
+			continue
+		}
+
+		node := &rDomain.ReplyNode{
+			Reply:   reply,
+			Replies: make([]*rDomain.ReplyNode, 0),
+		}
+		nodes[reply.ID] = node
+		thread.RepliesCount++
+		if reply.ParentID.Valid {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			thread.Replies = append(thread.Replies, node)
+		}
+	}
+	return threads, int(numPages), nil
+}
+
+// moderationScope leaves out moderated comments or replies, see
+// CommentListOptions
+func moderationScope(opts *rDomain.CommentListOptions) func(query *gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if opts.IncludeModerated {
+			return query
+		}
+		if opts.ViewerID != uuid.Nil {
+			return query.Where("(moderation = ? or author = ?)", rDomain.CommentVisible, opts.ViewerID)
+		}
+		return query.Where("moderation = ?", rDomain.CommentVisible)
+	}
+}
+
+func (r *commentRepository) CountReplies(ctx context.Context, commentId uuid.UUID) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.CommentReply{}).
+		Where("commentId = ?", commentId).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting comment replies: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *commentRepository) Vote(ctx context.Context, commentId uuid.UUID, userId uuid.UUID, helpful bool) error {
+	vote := rDomain.CommentVote{
+		ID:        uuid.New(),
+		CommentID: commentId,
+		UserID:    userId,
+		Helpful:   helpful,
+	}
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			DoUpdates: clause.AssignmentColumns([]string{"helpful"}),
+		}).
+		Create(&vote).Error
+	if err != nil {
+		return fmt.Errorf("voting for comment: %w", err)
+	}
+	return nil
+}
+
+func (r *commentRepository) RemoveVote(ctx context.Context, commentId uuid.UUID, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Where("commentId = ?", commentId).
+		Where("userId = ?", userId).
+		Delete(&rDomain.CommentVote{}).Error
+	if err != nil {
+		return fmt.Errorf("removing comment vote: %w", err)
+	}
+	return nil
+}
+
+func (r *commentRepository) GetAllBySaladIDSorted(ctx context.Context, saladId uuid.UUID,
+	opts *rDomain.CommentListOptions, page int) ([]*rDomain.RatedComment, int, error) {
+	if opts == nil {
+		opts = &rDomain.CommentListOptions{}
+	}
+	query := func() *gorm.DB {
+		query := r.db.WithContext(ctx).
+			Model(&rDomain.Comment{}).
+			Where("salad = ?", saladId).
+			Scopes(moderationScope(opts))
+		if opts.Rating != 0 {
+			query = query.Where("rating = ?", opts.Rating)
+		}
+		return query
+	}
+
+	type ratedComment struct {
+		rDomain.Comment
+		CreatedAt  time.Time `gorm:"column:createdAt"`
+		Helpful    int       `gorm:"column:helpful"`
+		Unhelpful  int       `gorm:"column:unhelpful"`
+		Moderation int       `gorm:"column:moderation"`
+	}
+	var dbComments []*ratedComment
+	err := query().
+		Select(`comment.*,
+			(select count(*) from saladRecipes.commentVote
+				where commentVote.commentId = comment.id and commentVote.helpful) as helpful,
+			(select count(*) from saladRecipes.commentVote
+				where commentVote.commentId = comment.id and not commentVote.helpful) as unhelpful`).
+		Order(commentOrder(opts.Sort)).
+		Order("createdAt desc").
+		Order("id").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Scan(&dbComments).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting sorted comments by salad id: %w", err)
+	}
+
+	resComments := make([]*rDomain.RatedComment, 0)
+	for _, comment := range dbComments {
+		resComments = append(resComments, &rDomain.RatedComment{
+			Comment:    rDomain.ToCommentBL(&comment.Comment),
+			CreatedAt:  comment.CreatedAt,
+			Helpful:    comment.Helpful,
+			Unhelpful:  comment.Unhelpful,
+			Moderation: comment.Moderation,
+		})
+	}
+
+	var count int64
+	err = query().
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting sorted comments by salad id: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return resComments, int(numPages), nil
+}
+
+func (r *commentRepository) GetRevisions(ctx context.Context, commentId uuid.UUID) ([]*rDomain.CommentRevision, error) {
+	var revisions []*rDomain.CommentRevision
+	err := r.db.WithContext(ctx).
+		Where("commentId = ?", commentId).
+		Order("editedAt desc").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting comment revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+func (r *commentRepository) SetModeration(ctx context.Context, commentId uuid.UUID, moderation int, reason string) error {
+	err := setModeration(r.db.WithContext(ctx), &rDomain.Comment{}, commentId, moderation, reason)
+	if err != nil {
+		return fmt.Errorf("setting comment moderation: %w", err)
+	}
+	return nil
+}
+
+func (r *commentRepository) SetReplyModeration(ctx context.Context, replyId uuid.UUID, moderation int, reason string) error {
+	err := setModeration(r.db.WithContext(ctx), &rDomain.CommentReply{}, replyId, moderation, reason)
+	if err != nil {
+		return fmt.Errorf("setting reply moderation: %w", err)
+	}
+	return nil
+}
+
+// setModeration stores the moderation state of a comment or a reply, the
+// tables share the moderation columns
+func setModeration(db *gorm.DB, model interface{}, id uuid.UUID, moderation int, reason string) error {
+	if moderation < rDomain.CommentVisible || moderation > rDomain.CommentHidden {
+		return rDomain.ErrUnknownModeration
+	}
+	moderatedAt := sql.NullTime{Time: time.Now(), Valid: moderation != rDomain.CommentVisible}
+	res := db.Model(model).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"moderation":       moderation,
+			"moderationReason": reason,
+			"moderatedAt":      moderatedAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		err := db.Model(model).
+			Where("id = ?", id).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+func (r *commentRepository) GetFlagged(ctx context.Context, page int) ([]*rDomain.ModeratedComment, int, error) {
+	type moderatedComment struct {
+		rDomain.Comment
+		Moderation  int          `gorm:"column:moderation"`
+		Reason      string       `gorm:"column:moderationReason"`
+		ModeratedAt sql.NullTime `gorm:"column:moderatedAt"`
+	}
+	var dbComments []*moderatedComment
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.Comment{}).
+		Select("comment.*").
+		Where("moderation = ?", rDomain.CommentFlagged).
+		Order("moderatedAt").
+		Order("id").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Scan(&dbComments).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting flagged comments: %w", err)
+	}
+
+	resComments := make([]*rDomain.ModeratedComment, 0)
+	for _, comment := range dbComments {
+		resComments = append(resComments, &rDomain.ModeratedComment{
+			Comment:     rDomain.ToCommentBL(&comment.Comment),
+			Moderation:  comment.Moderation,
+			Reason:      comment.Reason,
+			ModeratedAt: comment.ModeratedAt.Time,
+		})
+	}
+
+	var count int64
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Comment{}).
+		Where("moderation = ?", rDomain.CommentFlagged).
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting flagged comments: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return resComments, int(numPages), nil
+}
+
+func commentOrder(sort int) string {
+	switch sort {
+	case rDomain.CommentSortHighestRating:
+		return "rating desc"
+	case rDomain.CommentSortLowestRating:
+		return "rating"
+	case rDomain.CommentSortMostHelpful:
+		return "helpful desc, unhelpful"
+	default:
+		return "createdAt desc"
+	}
+}
@@ -7,27 +7,33 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 )
 
+const DefaultAutocompleteLimit = 10
+
 type ingredientRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	index *ingredientIndex
 }
 
 func NewIngredientRepository(db *gorm.DB) rDomain.IIngredientRepository {
 	return &ingredientRepository{
-		db: db,
+		db:    db,
+		index: newIngredientIndex(),
 	}
 }
 
-func (r *ingredientRepository) Create(ctx context.Context, ingredient *domain.Ingredient) error {
+func (r *ingredientRepository) Create(ctx context.Context, ingredient *domain.Ingredient) (uuid.UUID, error) {
 	dbIngredient := rDomain.ToIngredientDB(ingredient)
-	dbIngredient.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbIngredient).Error
+	dbIngredient.ID = idOrNew(ingredient.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbIngredient, dbIngredient.ID)
 	if err != nil {
-		return fmt.Errorf("creating ingredient: %w", err)
+		return uuid.Nil, fmt.Errorf("creating ingredient: %w", err)
 	}
-	return nil
+	r.index.put(dbIngredient.ID, dbIngredient.Name)
+	return dbIngredient.ID, nil
 }
 
 func (r *ingredientRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.Ingredient, error) {
@@ -94,34 +100,98 @@ func (r *ingredientRepository) GetAllByRecipeId(ctx context.Context, id uuid.UUI
 	return resIngredients, nil
 }
 
+// Update changes an existing ingredient, Save alone would create a missing
+// one behind the back of the index
 func (r *ingredientRepository) Update(ctx context.Context, ingredient *domain.Ingredient) error {
 	dbIngredient := rDomain.ToIngredientDB(ingredient)
-	err := r.db.WithContext(ctx).
-		Save(&dbIngredient).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		err := tx.Model(&rDomain.Ingredient{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", dbIngredient.ID).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Save(&dbIngredient).Error
+	})
 	if err != nil {
 		return fmt.Errorf("updating ingredient: %w", err)
 	}
+	r.index.put(dbIngredient.ID, dbIngredient.Name)
 	return nil
 }
 
 func (r *ingredientRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Delete(&rDomain.Ingredient{}, id).Error
+	return r.DeleteWithOptions(ctx, id, nil)
+}
+
+func (r *ingredientRepository) DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *rDomain.DeleteOptions) error {
+	if opts != nil && opts.ReplacementId != uuid.Nil {
+		_, err := r.Merge(ctx, opts.ReplacementId, []uuid.UUID{id})
+		if err != nil {
+			return fmt.Errorf("deleting ingredient by id: %w", err)
+		}
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteWithUsages(tx, &rDomain.Ingredient{}, id, nil, ingredientUsages, nil)
+	})
 	if err != nil {
 		return fmt.Errorf("deleting ingredient by id: %w", err)
 	}
+	r.index.remove(id)
 	return nil
 }
 
+func (r *ingredientRepository) UsageCount(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.IngredientLink{}).
+		Where("ingredientId = ?", id).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting ingredient usages: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *ingredientRepository) GetUsages(ctx context.Context, id uuid.UUID) ([]*rDomain.Usage, error) {
+	usages, err := ingredientUsages(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, fmt.Errorf("getting ingredient usages: %w", err)
+	}
+	return usages, nil
+}
+
+func ingredientUsages(db *gorm.DB, id uuid.UUID) ([]*rDomain.Usage, error) {
+	var usages []*rDomain.Usage
+	err := db.Table("recipeIngredient").
+		Select("? as kind, recipe.id as id, salad.name as name", rDomain.UsageKindRecipe).
+		Joins("join recipe on recipe.id = recipeIngredient.recipeId").
+		Joins("join salad on salad.id = recipe.saladId").
+		Where("recipeIngredient.ingredientId = ?", id).
+		Order("salad.name").
+		Scan(&usages).Error
+	return usages, err
+}
+
 func (r *ingredientRepository) Link(ctx context.Context, recipeId uuid.UUID, ingredientId uuid.UUID) (uuid.UUID, error) {
 	link := rDomain.IngredientLink{
 		ID:           uuid.New(), // TODO
 		RecipeId:     recipeId,
 		IngredientId: ingredientId,
 	}
-	err := r.db.WithContext(ctx).
-		Select("id", "recipeId", "ingredientId").
-		Create(&link).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Select("id", "recipeId", "ingredientId").
+				Create(&link).Error
+		})
+	})
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("linking ingredient: %w", err)
 	}
@@ -129,12 +199,200 @@ func (r *ingredientRepository) Link(ctx context.Context, recipeId uuid.UUID, ing
 }
 
 func (r *ingredientRepository) Unlink(ctx context.Context, recipeId uuid.UUID, ingredientId uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Where("recipeID = ?", recipeId).
-		Where("ingredientId = ?", ingredientId).
-		Delete(&rDomain.IngredientLink{}).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Where("recipeID = ?", recipeId).
+				Where("ingredientId = ?", ingredientId).
+				Delete(&rDomain.IngredientLink{}).Error
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("unlinking ingredient by recipe id: %w", err)
 	}
 	return nil
 }
+
+func (r *ingredientRepository) Autocomplete(ctx context.Context, query string, limit int) ([]*domain.Ingredient, error) {
+	if limit <= 0 {
+		limit = DefaultAutocompleteLimit
+	}
+	index, err := r.loadIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("autocompleting ingredients: %w", err)
+	}
+
+	ids := index.search(query, limit)
+	if len(ids) == 0 {
+		return make([]*domain.Ingredient, 0), nil
+	}
+
+	var ingredients []*rDomain.Ingredient
+	err = r.db.WithContext(ctx).
+		Find(&ingredients, ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("autocompleting ingredients: %w", err)
+	}
+
+	byId := make(map[uuid.UUID]*rDomain.Ingredient, len(ingredients))
+	for _, ingredient := range ingredients {
+		byId[ingredient.ID] = ingredient
+	}
+	resIngredients := make([]*domain.Ingredient, 0, len(ids))
+	for _, id := range ids {
+		if ingredient, ok := byId[id]; ok {
+			resIngredients = append(resIngredients, rDomain.ToIngredientBL(ingredient))
+		}
+	}
+	return resIngredients, nil
+}
+
+func (r *ingredientRepository) Search(ctx context.Context, query string, page int) ([]*domain.Ingredient, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+	aliasMatches := r.db.
+		Model(&rDomain.IngredientAlias{}).
+		Select("ingredientId").
+		Where("alias like ?", pattern)
+
+	var ingredients []*rDomain.Ingredient
+	err := r.db.WithContext(ctx).
+		Where("name like ?", pattern).
+		Or("id in (?)", aliasMatches).
+		Order("name").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Find(&ingredients).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("searching ingredients: %w", err)
+	}
+
+	resIngredients := make([]*domain.Ingredient, 0)
+	for _, ingredient := range ingredients {
+		resIngredients = append(resIngredients, rDomain.ToIngredientBL(ingredient))
+	}
+
+	var count int64
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Ingredient{}).
+		Where("name like ?", pattern).
+		Or("id in (?)", aliasMatches).
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("searching ingredients (counting): %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return resIngredients, int(numPages), nil
+}
+
+func (r *ingredientRepository) AddAlias(ctx context.Context, ingredientId uuid.UUID, alias string) (uuid.UUID, error) {
+	dbAlias := rDomain.IngredientAlias{
+		ID:           uuid.New(),
+		IngredientId: ingredientId,
+		Alias:        strings.TrimSpace(alias),
+	}
+	if dbAlias.Alias == "" {
+		return uuid.Nil, fmt.Errorf("adding ingredient alias: %w", rDomain.ErrEmptyAlias)
+	}
+	err := r.db.WithContext(ctx).
+		Create(&dbAlias).Error
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("adding ingredient alias: %w", err)
+	}
+	r.index.putAlias(dbAlias.IngredientId, dbAlias.Alias)
+	return dbAlias.ID, nil
+}
+
+func (r *ingredientRepository) RemoveAlias(ctx context.Context, aliasId uuid.UUID) error {
+	var dbAlias rDomain.IngredientAlias
+	err := r.db.WithContext(ctx).
+		First(&dbAlias, aliasId).Error
+	if err != nil {
+		return fmt.Errorf("removing ingredient alias: %w", err)
+	}
+
+	err = r.db.WithContext(ctx).
+		Delete(&rDomain.IngredientAlias{}, aliasId).Error
+	if err != nil {
+		return fmt.Errorf("removing ingredient alias: %w", err)
+	}
+	r.index.removeAlias(dbAlias.IngredientId, dbAlias.Alias)
+	return nil
+}
+
+func (r *ingredientRepository) GetAliases(ctx context.Context, ingredientId uuid.UUID) ([]*rDomain.IngredientAlias, error) {
+	var aliases []*rDomain.IngredientAlias
+	err := r.db.WithContext(ctx).
+		Where("ingredientId = ?", ingredientId).
+		Order("alias").
+		Find(&aliases).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting ingredient aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+func (r *ingredientRepository) GetByAlias(ctx context.Context, alias string) (*domain.Ingredient, error) {
+	alias = strings.TrimSpace(alias)
+	var ingredient rDomain.Ingredient
+	err := r.db.WithContext(ctx).
+		Where("name = ?", alias).
+		Or("id in (?)", r.db.
+			Model(&rDomain.IngredientAlias{}).
+			Select("ingredientId").
+			Where("alias = ?", alias)).
+		First(&ingredient).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting ingredient by alias: %w", err)
+	}
+	return rDomain.ToIngredientBL(&ingredient), nil
+}
+
+// loadIndex returns the index to search, loading it if needed. Under a steady
+// stream of changes the data just read is searched without being installed
+func (r *ingredientRepository) loadIndex(ctx context.Context) (*ingredientIndex, error) {
+	for attempt := 1; ; attempt++ {
+		if r.index.isLoaded() {
+			return r.index, nil
+		}
+		generation := r.index.currentGeneration()
+
+		var ingredients []*indexedIngredient
+		err := r.db.WithContext(ctx).
+			Model(&rDomain.Ingredient{}).
+			Select("id", "name").
+			Scan(&ingredients).Error
+		if err != nil {
+			return nil, fmt.Errorf("loading ingredient index: %w", err)
+		}
+
+		var aliases []*rDomain.IngredientAlias
+		err = r.db.WithContext(ctx).
+			Find(&aliases).Error
+		if err != nil {
+			return nil, fmt.Errorf("loading ingredient index (aliases): %w", err)
+		}
+
+		byId := make(map[uuid.UUID]*indexedIngredient, len(ingredients))
+		for _, ingredient := range ingredients {
+			byId[ingredient.ID] = ingredient
+		}
+		for _, alias := range aliases {
+			if ingredient, ok := byId[alias.IngredientId]; ok {
+				ingredient.Aliases = append(ingredient.Aliases, alias.Alias)
+			}
+		}
+		// an ingredient changed during the read is not in it, reset then
+		// refuses the data and the loop reads again
+		if r.index.reset(ingredients, generation) {
+			return r.index, nil
+		}
+		if attempt == maxCacheLoadAttempts {
+			index := newIngredientIndex()
+			index.reset(ingredients, index.currentGeneration())
+			return index, nil
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package mysql
+
+import (
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	minTrigramSimilarity = 0.3
+	maxEditDistanceRatio = 4
+)
+
+type indexEntry struct {
+	ingredient *indexedIngredient
+	term       string
+	trigrams   map[string]struct{}
+}
+
+type indexedIngredient struct {
+	ID      uuid.UUID
+	Name    string
+	Aliases []string `gorm:"-"`
+}
+
+type indexMatch struct {
+	id       uuid.UUID
+	name     string
+	prefix   bool
+	distance int
+	score    float64
+}
+
+// ingredientIndex is filled lazily from the database. Every change bumps
+// generation, so a load that raced with a change is not installed
+type ingredientIndex struct {
+	mu          sync.RWMutex
+	loaded      bool
+	generation  uint64
+	ingredients map[uuid.UUID]*indexedIngredient
+	entries     map[uuid.UUID][]*indexEntry
+}
+
+func newIngredientIndex() *ingredientIndex {
+	return &ingredientIndex{
+		ingredients: make(map[uuid.UUID]*indexedIngredient),
+		entries:     make(map[uuid.UUID][]*indexEntry),
+	}
+}
+
+func (idx *ingredientIndex) isLoaded() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.loaded
+}
+
+func (idx *ingredientIndex) currentGeneration() uint64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.generation
+}
+
+// reset installs ingredients read while the index was at generation, it
+// reports false if the index changed since then
+func (idx *ingredientIndex) reset(ingredients []*indexedIngredient, generation uint64) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.generation != generation {
+		return false
+	}
+	idx.ingredients = make(map[uuid.UUID]*indexedIngredient, len(ingredients))
+	idx.entries = make(map[uuid.UUID][]*indexEntry, len(ingredients))
+	for _, ingredient := range ingredients {
+		idx.ingredients[ingredient.ID] = ingredient
+		idx.entries[ingredient.ID] = newIndexEntries(ingredient)
+	}
+	idx.loaded = true
+	return true
+}
+
+func (idx *ingredientIndex) put(id uuid.UUID, name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.generation++
+	if !idx.loaded {
+		return
+	}
+	ingredient, ok := idx.ingredients[id]
+	if !ok {
+		ingredient = &indexedIngredient{ID: id}
+		idx.ingredients[id] = ingredient
+	}
+	ingredient.Name = name
+	idx.entries[id] = newIndexEntries(ingredient)
+}
+
+func (idx *ingredientIndex) putAlias(id uuid.UUID, alias string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.generation++
+	ingredient, ok := idx.ingredients[id]
+	if !ok {
+		return
+	}
+	ingredient.Aliases = append(ingredient.Aliases, alias)
+	idx.entries[id] = newIndexEntries(ingredient)
+}
+
+func (idx *ingredientIndex) removeAlias(id uuid.UUID, alias string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.generation++
+	ingredient, ok := idx.ingredients[id]
+	if !ok {
+		return
+	}
+	aliases := make([]string, 0, len(ingredient.Aliases))
+	for _, a := range ingredient.Aliases {
+		if a != alias {
+			aliases = append(aliases, a)
+		}
+	}
+	ingredient.Aliases = aliases
+	idx.entries[id] = newIndexEntries(ingredient)
+}
+
+func (idx *ingredientIndex) remove(id uuid.UUID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.generation++
+	delete(idx.ingredients, id)
+	delete(idx.entries, id)
+}
+
+func (idx *ingredientIndex) invalidate() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.generation++
+	idx.loaded = false
+	idx.ingredients = make(map[uuid.UUID]*indexedIngredient)
+	idx.entries = make(map[uuid.UUID][]*indexEntry)
+}
+
+func newIndexEntries(ingredient *indexedIngredient) []*indexEntry {
+	terms := append([]string{ingredient.Name}, ingredient.Aliases...)
+	entries := make([]*indexEntry, 0, len(terms))
+	for _, term := range terms {
+		normalized := normalizeText(term)
+		if normalized == "" {
+			continue
+		}
+		entries = append(entries, &indexEntry{
+			ingredient: ingredient,
+			term:       normalized,
+			trigrams:   trigrams(normalized),
+		})
+	}
+	return entries
+}
+
+func (idx *ingredientIndex) search(query string, limit int) []uuid.UUID {
+	query = normalizeText(query)
+	if query == "" || limit <= 0 {
+		return []uuid.UUID{}
+	}
+	queryTrigrams := trigrams(query)
+	queryLen := utf8.RuneCountInString(query)
+	maxDistance := queryLen / maxEditDistanceRatio
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	idx.mu.RLock()
+	matches := make([]*indexMatch, 0)
+	for id, entries := range idx.entries {
+		var best *indexMatch
+		for _, entry := range entries {
+			match := &indexMatch{
+				id:   id,
+				name: entry.ingredient.Name,
+			}
+			if strings.HasPrefix(entry.term, query) {
+				match.prefix = true
+				match.distance = utf8.RuneCountInString(entry.term) - queryLen
+			} else {
+				match.distance = prefixEditDistance(query, entry.term)
+				match.score = trigramSimilarity(queryTrigrams, entry.trigrams)
+				if match.distance > maxDistance && match.score < minTrigramSimilarity {
+					continue
+				}
+			}
+			if best == nil || match.better(best) {
+				best = match
+			}
+		}
+		if best != nil {
+			matches = append(matches, best)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].better(matches[j])
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	ids := make([]uuid.UUID, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.id)
+	}
+	return ids
+}
+
+func (m *indexMatch) better(other *indexMatch) bool {
+	if m.prefix != other.prefix {
+		return m.prefix
+	}
+	if m.distance != other.distance {
+		return m.distance < other.distance
+	}
+	if m.score != other.score {
+		return m.score > other.score
+	}
+	return m.name < other.name
+}
+
+func trigrams(str string) map[string]struct{} {
+	runes := []rune("  " + str + " ")
+	res := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		res[string(runes[i:i+3])] = struct{}{}
+	}
+	return res
+}
+
+func trigramSimilarity(a map[string]struct{}, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// prefixEditDistance returns the smallest Levenshtein distance between query
+// and any prefix of term, so partially typed words still match
+func prefixEditDistance(query string, term string) int {
+	q := []rune(query)
+	t := []rune(term)
+
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(q); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if q[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	res := prev[0]
+	for _, d := range prev {
+		if d < res {
+			res = d
+		}
+	}
+	return res
+}
@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ingredientIndex_ResetAfterConcurrentPut(t *testing.T) {
+	idx := newIngredientIndex()
+	stale := []*indexedIngredient{{ID: uuid.UUID{1}, Name: "морковь"}}
+
+	generation := idx.currentGeneration()
+	// ingredient created after the load read the table
+	idx.put(uuid.UUID{2}, "свекла")
+
+	require.False(t, idx.reset(stale, generation))
+	require.False(t, idx.isLoaded())
+
+	fresh := []*indexedIngredient{
+		{ID: uuid.UUID{1}, Name: "морковь"},
+		{ID: uuid.UUID{2}, Name: "свекла"},
+	}
+	require.True(t, idx.reset(fresh, idx.currentGeneration()))
+	require.Equal(t, []uuid.UUID{{2}}, idx.search("свекл", 5))
+}
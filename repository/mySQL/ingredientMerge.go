@@ -0,0 +1,222 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"slices"
+	"strings"
+)
+
+type mergeLink struct {
+	ID           uuid.UUID `gorm:"column:id"`
+	RecipeId     uuid.UUID `gorm:"column:recipeId"`
+	IngredientId uuid.UUID `gorm:"column:ingredientId"`
+	Measurement  uuid.UUID `gorm:"column:measurement"`
+	Amount       int       `gorm:"column:amount"`
+	Grams        *int      `gorm:"column:grams"`
+}
+
+func (r *ingredientRepository) Merge(ctx context.Context, keepId uuid.UUID, duplicateIds []uuid.UUID) (*rDomain.IngredientMergeReport, error) {
+	duplicates := make([]uuid.UUID, 0, len(duplicateIds))
+	for _, id := range duplicateIds {
+		if id == keepId {
+			return nil, fmt.Errorf("merging ingredients: %w", rDomain.ErrMergeIntoItself)
+		}
+		if !slices.Contains(duplicates, id) {
+			duplicates = append(duplicates, id)
+		}
+	}
+
+	report := &rDomain.IngredientMergeReport{
+		KeptId:         keepId,
+		DeletedIds:     make([]uuid.UUID, 0),
+		RepointedLinks: make([]uuid.UUID, 0),
+		MergedLinks:    make([]*rDomain.MergedIngredientLink, 0),
+	}
+	if len(duplicates) == 0 {
+		return report, nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked []*rDomain.Ingredient
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Find(&locked, append([]uuid.UUID{keepId}, duplicates...)).Error
+		if err != nil {
+			return fmt.Errorf("locking ingredients: %w", err)
+		}
+		if len(locked) != len(duplicates)+1 {
+			return fmt.Errorf("locking ingredients: %w", gorm.ErrRecordNotFound)
+		}
+
+		var links []*mergeLink
+		err = tx.Table("recipeIngredient").
+			Select("recipeIngredient.id", "recipeId", "ingredientId", "measurement", "amount", "measurement.grams").
+			Joins("join measurement on measurement.id = recipeIngredient.measurement").
+			Where("ingredientId in ?", append([]uuid.UUID{keepId}, duplicates...)).
+			Order("recipeIngredient.id").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scan(&links).Error
+		if err != nil {
+			return fmt.Errorf("getting recipe links: %w", err)
+		}
+
+		kept := make(map[uuid.UUID]*mergeLink)
+		recipeIds := make([]uuid.UUID, 0)
+		for _, link := range links {
+			if link.IngredientId == keepId {
+				kept[link.RecipeId] = link
+			} else if !slices.Contains(recipeIds, link.RecipeId) {
+				recipeIds = append(recipeIds, link.RecipeId)
+			}
+		}
+		// recipes are locked in the same order by every merge
+		slices.SortFunc(recipeIds, func(a, b uuid.UUID) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		for _, recipeId := range recipeIds {
+			err = recordRecipeVersion(tx, recipeId)
+			if err != nil {
+				return fmt.Errorf("recording recipe version: %w", err)
+			}
+		}
+		for _, link := range links {
+			if link.IngredientId == keepId {
+				continue
+			}
+
+			target, ok := kept[link.RecipeId]
+			if !ok {
+				err = tx.Model(&rDomain.IngredientLink{}).
+					Where("id = ?", link.ID).
+					Update("ingredientId", keepId).Error
+				if err != nil {
+					return fmt.Errorf("repointing recipe link: %w", err)
+				}
+				link.IngredientId = keepId
+				kept[link.RecipeId] = link
+				report.RepointedLinks = append(report.RepointedLinks, link.ID)
+				continue
+			}
+
+			merged, err := mergeAmounts(link, target)
+			if err != nil {
+				return fmt.Errorf("merging recipe %s links: %w", link.RecipeId, err)
+			}
+			err = repointStepIngredients(tx, link.ID, target.ID)
+			if err != nil {
+				return fmt.Errorf("repointing step ingredients: %w", err)
+			}
+			err = tx.Delete(&rDomain.IngredientLink{}, link.ID).Error
+			if err != nil {
+				return fmt.Errorf("deleting duplicate recipe link: %w", err)
+			}
+			err = tx.Model(&rDomain.IngredientLink{}).
+				Where("id = ?", target.ID).
+				Updates(map[string]interface{}{
+					"measurement": merged.Measurement,
+					"amount":      merged.AmountAfter,
+				}).Error
+			if err != nil {
+				return fmt.Errorf("updating merged recipe link: %w", err)
+			}
+			merged.RecipeId = link.RecipeId
+			merged.LinkId = target.ID
+			merged.RemovedLinkId = link.ID
+			report.MergedLinks = append(report.MergedLinks, merged)
+
+			if merged.Measurement != target.Measurement {
+				target.Measurement = link.Measurement
+				target.Grams = link.Grams
+			}
+			target.Amount = merged.AmountAfter
+		}
+		for _, recipeId := range recipeIds {
+			err = recordRecipeVersion(tx, recipeId)
+			if err != nil {
+				return fmt.Errorf("recording recipe version: %w", err)
+			}
+		}
+
+		res := tx.Model(&rDomain.IngredientAlias{}).
+			Where("ingredientId in ?", duplicates).
+			Update("ingredientId", keepId)
+		if res.Error != nil {
+			return fmt.Errorf("repointing aliases: %w", res.Error)
+		}
+		report.RepointedAliases = int(res.RowsAffected)
+
+		err = tx.Delete(&rDomain.Ingredient{}, duplicates).Error
+		if err != nil {
+			return fmt.Errorf("deleting duplicates: %w", err)
+		}
+		report.DeletedIds = duplicates
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("merging ingredients: %w", err)
+	}
+
+	r.index.invalidate()
+	return report, nil
+}
+
+// repointStepIngredients moves the steps of link fromId to link toId. A step
+// that already uses toId keeps its single row, as (stepId, linkId) is unique
+func repointStepIngredients(tx *gorm.DB, fromId uuid.UUID, toId uuid.UUID) error {
+	var stepIds []uuid.UUID
+	err := tx.Model(&rDomain.StepIngredientLink{}).
+		Where("linkId = ?", toId).
+		Pluck("stepId", &stepIds).Error
+	if err != nil {
+		return err
+	}
+	if len(stepIds) != 0 {
+		err = tx.Where("linkId = ?", fromId).
+			Where("stepId in ?", stepIds).
+			Delete(&rDomain.StepIngredientLink{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Model(&rDomain.StepIngredientLink{}).
+		Where("linkId = ?", fromId).
+		Update("linkId", toId).Error
+}
+
+// mergeAmounts sums the amounts of two links to the same ingredient. The sum
+// is kept in the smaller of the two units, so converting never rounds a small
+// amount away or up to a whole larger unit
+func mergeAmounts(from *mergeLink, to *mergeLink) (*rDomain.MergedIngredientLink, error) {
+	merged := &rDomain.MergedIngredientLink{
+		MeasurementBefore: to.Measurement,
+		MeasurementAdded:  from.Measurement,
+		Measurement:       to.Measurement,
+		AmountBefore:      to.Amount,
+		AmountAdded:       from.Amount,
+	}
+	before, added := to.Amount, from.Amount
+	if from.Measurement != to.Measurement {
+		if from.Grams == nil || to.Grams == nil || *from.Grams <= 0 || *to.Grams <= 0 {
+			return nil, rDomain.ErrIncompatibleMeasurements
+		}
+		if *from.Grams < *to.Grams {
+			merged.Measurement = from.Measurement
+			before = convertAmount(to.Amount, *to.Grams, *from.Grams)
+		} else {
+			added = convertAmount(from.Amount, *from.Grams, *to.Grams)
+		}
+	}
+	merged.AmountAfter = before + added
+	return merged, nil
+}
+
+// convertAmount converts an amount between units given by their weight in
+// grams, the result is rounded to the nearest whole number
+func convertAmount(amount int, fromGrams int, toGrams int) int {
+	return int(math.Round(float64(amount) * float64(fromGrams) / float64(toGrams)))
+}
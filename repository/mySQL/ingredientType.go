@@ -19,15 +19,14 @@ func NewIngredientTypeRepository(db *gorm.DB) rDomain.IIngredientTypeRepository
 	}
 }
 
-func (r *ingredientTypeRepository) Create(ctx context.Context, ingredientType *domain.IngredientType) error {
+func (r *ingredientTypeRepository) Create(ctx context.Context, ingredientType *domain.IngredientType) (uuid.UUID, error) {
 	dbIngredientType := rDomain.ToIngredientTypeDB(ingredientType)
-	dbIngredientType.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbIngredientType).Error
+	dbIngredientType.ID = idOrNew(ingredientType.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbIngredientType, dbIngredientType.ID)
 	if err != nil {
-		return fmt.Errorf("creating ingredient type: %w", err)
+		return uuid.Nil, fmt.Errorf("creating ingredient type: %w", err)
 	}
-	return nil
+	return dbIngredientType.ID, nil
 }
 
 func (r *ingredientTypeRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.IngredientType, error) {
@@ -67,10 +66,50 @@ func (r *ingredientTypeRepository) Update(ctx context.Context, measurement *doma
 }
 
 func (r *ingredientTypeRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Delete(&rDomain.IngredientType{}, id).Error
+	return r.DeleteWithOptions(ctx, id, nil)
+}
+
+func (r *ingredientTypeRepository) DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *rDomain.DeleteOptions) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteWithUsages(tx, &rDomain.IngredientType{}, id, opts, ingredientTypeUsages,
+			func(tx *gorm.DB, id uuid.UUID, replacementId uuid.UUID) error {
+				return tx.Model(&rDomain.Ingredient{}).
+					Where("type = ?", id).
+					Update("type", replacementId).Error
+			})
+	})
 	if err != nil {
 		return fmt.Errorf("deleting ingredient type by id: %w", err)
 	}
 	return nil
 }
+
+func (r *ingredientTypeRepository) UsageCount(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.Ingredient{}).
+		Where("type = ?", id).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting ingredient type usages: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *ingredientTypeRepository) GetUsages(ctx context.Context, id uuid.UUID) ([]*rDomain.Usage, error) {
+	usages, err := ingredientTypeUsages(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, fmt.Errorf("getting ingredient type usages: %w", err)
+	}
+	return usages, nil
+}
+
+func ingredientTypeUsages(db *gorm.DB, id uuid.UUID) ([]*rDomain.Usage, error) {
+	var usages []*rDomain.Usage
+	err := db.Table("ingredient").
+		Select("? as kind, id, name", rDomain.UsageKindIngredient).
+		Where("type = ?", id).
+		Order("name").
+		Scan(&usages).Error
+	return usages, err
+}
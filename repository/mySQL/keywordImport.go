@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const keywordImportBatchSize = 100
+
+// Import reads keywords in the "word[,category[,severity[,kind]]]" format,
+// one per line, lines starting with '#' are comments. Words already stored or
+// repeated in the input are compared case-insensitively and skipped. Either
+// every line is imported or none
+func (r *keywordValidatorRepository) Import(ctx context.Context, reader io.Reader) (*rDomain.KeywordImportReport, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	rules := make([]*rDomain.KeywordRule, 0)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("importing keywords: %w", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		rule, err := parseKeywordRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("importing keywords: line %d: %w", line, err)
+		}
+		if rule == nil {
+			continue
+		}
+		err = validateKeywordRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("importing keywords: line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	report := new(rDomain.KeywordImportReport)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []string
+		err := tx.Model(&rDomain.KeywordRule{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("word", &existing).Error
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]struct{}, len(existing)+len(rules))
+		for _, word := range existing {
+			seen[normalizeText(word)] = struct{}{}
+		}
+
+		added := make([]*rDomain.KeywordRule, 0, len(rules))
+		for _, rule := range rules {
+			key := normalizeText(rule.Word)
+			if _, ok := seen[key]; ok {
+				report.Skipped++
+				continue
+			}
+			seen[key] = struct{}{}
+			rule.ID = uuid.New()
+			added = append(added, rule)
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		err = tx.CreateInBatches(added, keywordImportBatchSize).Error
+		if err != nil {
+			return err
+		}
+		report.Added = len(added)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("importing keywords: %w", err)
+	}
+	if report.Added > 0 {
+		r.matcher.invalidate()
+	}
+	return report, nil
+}
+
+func (r *keywordValidatorRepository) Export(ctx context.Context, writer io.Writer) error {
+	rules, err := r.GetAllRules(ctx)
+	if err != nil {
+		return fmt.Errorf("exporting keywords: %w", err)
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	for _, rule := range rules {
+		record := []string{rule.Word, rule.Category, rDomain.KeywordSeverityNames[rule.Severity]}
+		if rule.Kind != rDomain.KeywordKindLiteral {
+			record = append(record, rDomain.KeywordKindNames[rule.Kind])
+		}
+		err = csvWriter.Write(record)
+		if err != nil {
+			return fmt.Errorf("exporting keywords: %w", err)
+		}
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	if err != nil {
+		return fmt.Errorf("exporting keywords: %w", err)
+	}
+	return nil
+}
+
+// parseKeywordRecord returns nil for blank lines
+func parseKeywordRecord(record []string) (*rDomain.KeywordRule, error) {
+	if len(record) > 4 {
+		return nil, fmt.Errorf("expected at most 4 fields, got %d", len(record))
+	}
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	if record[0] == "" {
+		if len(record) == 1 {
+			return nil, nil
+		}
+		return nil, rDomain.ErrEmptyKeyword
+	}
+
+	rule := &rDomain.KeywordRule{Word: record[0]}
+	if len(record) > 1 {
+		rule.Category = strings.ToLower(record[1])
+	}
+	if len(record) > 2 && record[2] != "" {
+		severity, err := parseKeywordEnum(record[2], rDomain.KeywordSeverityNames)
+		if err != nil {
+			return nil, rDomain.ErrUnknownKeywordSeverity
+		}
+		rule.Severity = severity
+	}
+	if len(record) > 3 && record[3] != "" {
+		kind, err := parseKeywordEnum(record[3], rDomain.KeywordKindNames)
+		if err != nil {
+			return nil, rDomain.ErrUnknownKeywordKind
+		}
+		rule.Kind = kind
+	}
+	return rule, nil
+}
+
+// parseKeywordEnum accepts either the name of a value or the value itself
+func parseKeywordEnum(str string, names map[int]string) (int, error) {
+	str = strings.ToLower(str)
+	for value, name := range names {
+		if name == str {
+			return value, nil
+		}
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := names[value]; !ok {
+		return 0, fmt.Errorf("unknown value %d", value)
+	}
+	return value, nil
+}
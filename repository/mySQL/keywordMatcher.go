@@ -0,0 +1,301 @@
+package mysql
+
+import (
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// foldedRunes maps look-alike latin letters, digits and symbols to the
+// cyrillic letter they usually stand for, so that "c0p" and "сор" compare equal
+var foldedRunes = map[rune]rune{
+	'ё': 'е',
+	'a': 'а', '@': 'а', '4': 'а',
+	'b': 'в', '8': 'в',
+	'e': 'е',
+	'3': 'з',
+	'k': 'к',
+	'm': 'м',
+	'h': 'н',
+	'o': 'о', '0': 'о',
+	'p': 'р',
+	'c': 'с', '$': 'с',
+	't': 'т', '7': 'т',
+	'y': 'у',
+	'x': 'х',
+	'6': 'б',
+}
+
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if folded, ok := foldedRunes[r]; ok {
+		return folded
+	}
+	return r
+}
+
+type matcherKeyword struct {
+	rule *rDomain.KeywordRule
+	size int
+}
+
+type matcherPattern struct {
+	rule   *rDomain.KeywordRule
+	re     *regexp.Regexp
+	folded bool
+}
+
+type matcherNode struct {
+	next map[rune]int
+	fail int
+	out  []int
+}
+
+// keywordSnapshot is an Aho–Corasick automaton over folded literal keywords.
+// Wildcard and regex rules do not fit the automaton and are kept as regexps.
+// A snapshot is never changed once compiled, so it can be matched against
+// while the matcher is invalidated
+type keywordSnapshot struct {
+	keywords []*matcherKeyword
+	nodes    []*matcherNode
+	patterns []*matcherPattern
+}
+
+// keywordMatcher holds the snapshot of the current rules. Every invalidation
+// bumps generation, so rules read before a change are not compiled in after it
+type keywordMatcher struct {
+	mu         sync.RWMutex
+	generation uint64
+	snapshot   *keywordSnapshot
+}
+
+func newKeywordMatcher() *keywordMatcher {
+	return &keywordMatcher{}
+}
+
+// current returns the installed snapshot, nil while the matcher is not loaded
+func (m *keywordMatcher) current() *keywordSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot
+}
+
+func (m *keywordMatcher) isLoaded() bool {
+	return m.current() != nil
+}
+
+func (m *keywordMatcher) currentGeneration() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.generation
+}
+
+func (m *keywordMatcher) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation++
+	m.snapshot = nil
+}
+
+// compilePattern turns a wildcard or regex rule into a regexp. Wildcards are
+// matched against folded text, so their letters are folded as well; regexes
+// are matched against the original text ignoring case
+func compilePattern(rule *rDomain.KeywordRule) (*regexp.Regexp, error) {
+	switch rule.Kind {
+	case rDomain.KeywordKindWildcard:
+		var expr strings.Builder
+		literals := 0
+		for _, r := range rule.Word {
+			switch r {
+			case '*':
+				expr.WriteString(`[\pL\pN]*`)
+			case '?':
+				expr.WriteString(`[\pL\pN]`)
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(foldRune(r))))
+				literals++
+			}
+		}
+		if literals == 0 {
+			return nil, fmt.Errorf("%w: wildcard has no letters", rDomain.ErrInvalidKeywordPattern)
+		}
+		return regexp.Compile(expr.String())
+	case rDomain.KeywordKindRegex:
+		re, err := regexp.Compile("(?i)" + rule.Word)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", rDomain.ErrInvalidKeywordPattern, err.Error())
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("%w: pattern matches empty text", rDomain.ErrInvalidKeywordPattern)
+		}
+		return re, nil
+	default:
+		return nil, rDomain.ErrUnknownKeywordKind
+	}
+}
+
+// compile builds a snapshot from rules read while the matcher was at
+// generation and installs it, it reports false if the matcher was invalidated
+// since then. The snapshot is returned either way. Rules are validated when
+// stored, so a pattern that does not compile was written around the
+// repository; it fails the whole compile rather than silently letting through
+// the text it was meant to catch
+func (m *keywordMatcher) compile(rules []*rDomain.KeywordRule, generation uint64) (*keywordSnapshot, bool, error) {
+	nodes := []*matcherNode{{next: make(map[rune]int)}}
+	keywords := make([]*matcherKeyword, 0, len(rules))
+	patterns := make([]*matcherPattern, 0)
+	for _, rule := range rules {
+		if rule.Kind != rDomain.KeywordKindLiteral {
+			re, err := compilePattern(rule)
+			if err != nil {
+				return nil, false, fmt.Errorf("compiling keyword rule %s: %w", rule.ID, err)
+			}
+			patterns = append(patterns, &matcherPattern{
+				rule:   rule,
+				re:     re,
+				folded: rule.Kind == rDomain.KeywordKindWildcard,
+			})
+			continue
+		}
+
+		state, size := 0, 0
+		for _, r := range rule.Word {
+			r = foldRune(r)
+			next, ok := nodes[state].next[r]
+			if !ok {
+				next = len(nodes)
+				nodes = append(nodes, &matcherNode{next: make(map[rune]int)})
+				nodes[state].next[r] = next
+			}
+			state = next
+			size++
+		}
+		if size == 0 {
+			continue
+		}
+		nodes[state].out = append(nodes[state].out, len(keywords))
+		keywords = append(keywords, &matcherKeyword{rule: rule, size: size})
+	}
+
+	queue := make([]int, 0, len(nodes))
+	for _, child := range nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range nodes[state].next {
+			fail := nodes[state].fail
+			for fail != 0 && !hasTransition(nodes[fail], r) {
+				fail = nodes[fail].fail
+			}
+			fail = nodes[fail].next[r]
+			nodes[child].fail = fail
+			nodes[child].out = append(nodes[child].out, nodes[fail].out...)
+			queue = append(queue, child)
+		}
+	}
+
+	snapshot := &keywordSnapshot{
+		keywords: keywords,
+		nodes:    nodes,
+		patterns: patterns,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation != generation {
+		return snapshot, false, nil
+	}
+	m.snapshot = snapshot
+	return snapshot, true, nil
+}
+
+func hasTransition(node *matcherNode, r rune) bool {
+	_, ok := node.next[r]
+	return ok
+}
+
+// match returns every, possibly overlapping, keyword occurrence in text.
+// Start and End are byte offsets into the original text
+func (s *keywordSnapshot) match(text string) []*rDomain.KeywordMatch {
+	matches := s.matchPatterns(text)
+	// byte offsets of the runes seen so far, used to find where a keyword
+	// ending at the current rune starts
+	offsets := make([]int, 0, utf8.RuneCountInString(text))
+	state := 0
+	for offset, r := range text {
+		offsets = append(offsets, offset)
+		_, width := utf8.DecodeRuneInString(text[offset:])
+		end := offset + width
+
+		r = foldRune(r)
+		for state != 0 && !hasTransition(s.nodes[state], r) {
+			state = s.nodes[state].fail
+		}
+		state = s.nodes[state].next[r]
+		for _, idx := range s.nodes[state].out {
+			keyword := s.keywords[idx]
+			start := offsets[len(offsets)-keyword.size]
+			matches = append(matches, newKeywordMatch(keyword.rule, text, start, end))
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End < matches[j].End
+	})
+	return matches
+}
+
+func (s *keywordSnapshot) matchPatterns(text string) []*rDomain.KeywordMatch {
+	matches := make([]*rDomain.KeywordMatch, 0)
+	if len(s.patterns) == 0 {
+		return matches
+	}
+
+	// folding keeps the number of runes but not their width, so positions
+	// found in the folded text are translated back through origOffsets
+	var folded strings.Builder
+	origOffsets := make([]int, 0, len(text)+1)
+	for offset, r := range text {
+		before := folded.Len()
+		folded.WriteRune(foldRune(r))
+		for i := before; i < folded.Len(); i++ {
+			origOffsets = append(origOffsets, offset)
+		}
+	}
+	origOffsets = append(origOffsets, len(text))
+	foldedText := folded.String()
+
+	for _, pattern := range s.patterns {
+		if !pattern.folded {
+			for _, loc := range pattern.re.FindAllStringIndex(text, -1) {
+				matches = append(matches, newKeywordMatch(pattern.rule, text, loc[0], loc[1]))
+			}
+			continue
+		}
+		for _, loc := range pattern.re.FindAllStringIndex(foldedText, -1) {
+			start, end := origOffsets[loc[0]], origOffsets[loc[1]]
+			matches = append(matches, newKeywordMatch(pattern.rule, text, start, end))
+		}
+	}
+	return matches
+}
+
+func newKeywordMatch(rule *rDomain.KeywordRule, text string, start int, end int) *rDomain.KeywordMatch {
+	return &rDomain.KeywordMatch{
+		WordID:   rule.ID,
+		Word:     rule.Word,
+		Category: rule.Category,
+		Severity: rule.Severity,
+		Start:    start,
+		End:      end,
+		Fragment: text[start:end],
+	}
+}
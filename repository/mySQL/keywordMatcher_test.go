@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_foldRune(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        rune
+		expected rune
+	}{
+		{name: "латинская буква", r: 'c', expected: 'с'},
+		{name: "заглавная латинская буква", r: 'P', expected: 'р'},
+		{name: "цифра", r: '0', expected: 'о'},
+		{name: "символ", r: '@', expected: 'а'},
+		{name: "ё", r: 'Ё', expected: 'е'},
+		{name: "кириллица без замены", r: 'Ж', expected: 'ж'},
+		{name: "латиница без замены", r: 'z', expected: 'z'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, foldRune(tt.r))
+		})
+	}
+}
+
+type testMatch struct {
+	word     string
+	start    int
+	end      int
+	fragment string
+}
+
+func Test_keywordMatcher_Match(t *testing.T) {
+	m := newKeywordMatcher()
+	snapshot, installed, err := m.compile([]*rDomain.KeywordRule{
+		{ID: uuid.UUID{1}, Word: "сор", Kind: rDomain.KeywordKindLiteral},
+		{ID: uuid.UUID{2}, Word: "сорняк", Kind: rDomain.KeywordKindLiteral},
+		{ID: uuid.UUID{3}, Word: "няк", Kind: rDomain.KeywordKindLiteral},
+		{ID: uuid.UUID{4}, Word: "пл*х", Kind: rDomain.KeywordKindWildcard},
+		{ID: uuid.UUID{5}, Word: `\bspam\b`, Kind: rDomain.KeywordKindRegex},
+	}, m.currentGeneration())
+	require.Nil(t, err)
+	require.True(t, installed)
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []testMatch
+	}{
+		{
+			name:     "пустой текст",
+			text:     "",
+			expected: []testMatch{},
+		}, // пустой текст
+		{
+			name:     "замена латиницей и цифрами",
+			text:     "c0p",
+			expected: []testMatch{{"сор", 0, 3, "c0p"}},
+		}, // замена латиницей и цифрами
+		{
+			name: "пересекающиеся слова",
+			text: "сорняк",
+			expected: []testMatch{
+				{"сор", 0, 6, "сор"},
+				{"сорняк", 0, 12, "сорняк"},
+				{"няк", 6, 12, "няк"},
+			},
+		}, // пересекающиеся слова
+		{
+			name:     "смещение после многобайтовых букв",
+			text:     "Ёлка СОР",
+			expected: []testMatch{{"сор", 9, 15, "СОР"}},
+		}, // смещение после многобайтовых букв
+		{
+			name:     "шаблон по сложенному тексту",
+			text:     "xx пл0х",
+			expected: []testMatch{{"пл*х", 3, 10, "пл0х"}},
+		}, // шаблон по сложенному тексту
+		{
+			name:     "регулярное выражение без учета регистра",
+			text:     "SPAM spammer",
+			expected: []testMatch{{`\bspam\b`, 0, 4, "SPAM"}},
+		}, // регулярное выражение без учета регистра
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := make([]testMatch, 0)
+			for _, match := range snapshot.match(tt.text) {
+				require.Equal(t, match.Fragment, tt.text[match.Start:match.End])
+				matches = append(matches, testMatch{match.Word, match.Start, match.End, match.Fragment})
+			}
+			require.Equal(t, tt.expected, matches)
+		})
+	}
+}
+
+func Test_keywordMatcher_CompileAfterInvalidate(t *testing.T) {
+	m := newKeywordMatcher()
+	stale := []*rDomain.KeywordRule{{ID: uuid.UUID{1}, Word: "сор"}}
+
+	generation := m.currentGeneration()
+	// rule created after the load read the table
+	m.invalidate()
+
+	_, installed, err := m.compile(stale, generation)
+	require.Nil(t, err)
+	require.False(t, installed)
+	require.False(t, m.isLoaded())
+
+	fresh := []*rDomain.KeywordRule{
+		{ID: uuid.UUID{1}, Word: "сор"},
+		{ID: uuid.UUID{2}, Word: "мусор"},
+	}
+	snapshot, installed, err := m.compile(fresh, m.currentGeneration())
+	require.Nil(t, err)
+	require.True(t, installed)
+	require.True(t, m.isLoaded())
+	require.Equal(t, 2, len(snapshot.match("мусор")))
+}
+
+func Test_keywordMatcher_CompileBrokenRule(t *testing.T) {
+	m := newKeywordMatcher()
+	rules := []*rDomain.KeywordRule{
+		{ID: uuid.UUID{1}, Word: "сор"},
+		{ID: uuid.UUID{2}, Word: "(", Kind: rDomain.KeywordKindRegex},
+	}
+
+	_, installed, err := m.compile(rules, m.currentGeneration())
+	require.True(t, errors.Is(err, rDomain.ErrInvalidKeywordPattern))
+	require.False(t, installed)
+	require.False(t, m.isLoaded())
+}
+
+func Test_keywordMatcher_SnapshotAfterInvalidate(t *testing.T) {
+	m := newKeywordMatcher()
+	_, installed, err := m.compile([]*rDomain.KeywordRule{{ID: uuid.UUID{1}, Word: "сор"}}, m.currentGeneration())
+	require.Nil(t, err)
+	require.True(t, installed)
+
+	snapshot := m.current()
+	// rule changed between the load and the match
+	m.invalidate()
+
+	require.False(t, m.isLoaded())
+	require.Nil(t, m.current())
+	require.Equal(t, 1, len(snapshot.match("сор")))
+}
@@ -7,27 +7,42 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"unicode/utf8"
 )
 
 type keywordValidatorRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	matcher *keywordMatcher
 }
 
 func NewKeywordValidatorRepository(db *gorm.DB) rDomain.IKeywordValidatorRepository {
 	return &keywordValidatorRepository{
-		db: db,
+		db:      db,
+		matcher: newKeywordMatcher(),
 	}
 }
 
-func (r *keywordValidatorRepository) Create(ctx context.Context, word *domain.KeyWord) error {
-	dbKeyWord := rDomain.ToKeyWordDB(word)
-	dbKeyWord.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbKeyWord).Error
+// Create stores the word as a literal rule with the default category and
+// severity, validated the same way as rules created by CreateRule
+func (r *keywordValidatorRepository) Create(ctx context.Context, word *domain.KeyWord) (uuid.UUID, error) {
+	rule := rDomain.KeywordRule{
+		ID:   idOrNew(word.ID),
+		Word: word.Word,
+		Kind: rDomain.KeywordKindLiteral,
+	}
+	err := validateKeywordRule(&rule)
 	if err != nil {
-		return fmt.Errorf("creating keyword: %w", err)
+		return uuid.Nil, fmt.Errorf("creating keyword: %w", err)
 	}
-	return nil
+
+	err = createWithId(r.db.WithContext(ctx), &rule, rule.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating keyword: %w", err)
+	}
+	r.matcher.invalidate()
+	return rule.ID, nil
 }
 
 func (r *keywordValidatorRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.KeyWord, error) {
@@ -43,6 +58,7 @@ func (r *keywordValidatorRepository) GetById(ctx context.Context, id uuid.UUID)
 func (r *keywordValidatorRepository) GetAll(ctx context.Context) (map[string]uuid.UUID, error) {
 	var keyWords []*rDomain.KeyWord
 	err := r.db.WithContext(ctx).
+		Where("kind = ?", rDomain.KeywordKindLiteral).
 		Find(&keyWords).Error
 	if err != nil {
 		return nil, fmt.Errorf("getting all keywords: %w", err)
@@ -55,13 +71,29 @@ func (r *keywordValidatorRepository) GetAll(ctx context.Context) (map[string]uui
 	return resKeywords, nil
 }
 
+// Update changes only the word, the rule keeps its kind, so a new word is
+// validated as a pattern of that kind. An unknown id stores a literal rule
 func (r *keywordValidatorRepository) Update(ctx context.Context, word *domain.KeyWord) error {
-	dbKeyword := rDomain.ToKeyWordDB(word)
-	err := r.db.WithContext(ctx).
-		Save(&dbKeyword).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rule rDomain.KeywordRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Limit(1).
+			Find(&rule, word.ID).Error
+		if err != nil {
+			return err
+		}
+		rule.ID = word.ID
+		rule.Word = word.Word
+		err = validateKeywordRule(&rule)
+		if err != nil {
+			return err
+		}
+		return tx.Save(&rule).Error
+	})
 	if err != nil {
 		return fmt.Errorf("updating keyword: %w", err)
 	}
+	r.matcher.invalidate()
 	return nil
 }
 
@@ -71,5 +103,183 @@ func (r *keywordValidatorRepository) DeleteById(ctx context.Context, id uuid.UUI
 	if err != nil {
 		return fmt.Errorf("deleting keyword by id: %w", err)
 	}
+	r.matcher.invalidate()
 	return nil
 }
+
+func (r *keywordValidatorRepository) CreateRule(ctx context.Context, rule *rDomain.KeywordRule) (uuid.UUID, error) {
+	dbRule := *rule
+	dbRule.ID = idOrNew(rule.ID)
+	err := validateKeywordRule(&dbRule)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating keyword rule: %w", err)
+	}
+
+	err = createWithId(r.db.WithContext(ctx), &dbRule, dbRule.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating keyword rule: %w", err)
+	}
+	r.matcher.invalidate()
+	return dbRule.ID, nil
+}
+
+func (r *keywordValidatorRepository) UpdateRule(ctx context.Context, rule *rDomain.KeywordRule) error {
+	dbRule := *rule
+	err := validateKeywordRule(&dbRule)
+	if err != nil {
+		return fmt.Errorf("updating keyword rule: %w", err)
+	}
+
+	err = r.db.WithContext(ctx).
+		Save(&dbRule).Error
+	if err != nil {
+		return fmt.Errorf("updating keyword rule: %w", err)
+	}
+	r.matcher.invalidate()
+	return nil
+}
+
+func (r *keywordValidatorRepository) GetAllRules(ctx context.Context) ([]*rDomain.KeywordRule, error) {
+	var rules []*rDomain.KeywordRule
+	err := r.db.WithContext(ctx).
+		Order("category").
+		Order("word").
+		Find(&rules).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting all keyword rules: %w", err)
+	}
+	return rules, nil
+}
+
+// validateKeywordRule fills in defaults for the category and severity and
+// checks that patterns compile
+func validateKeywordRule(rule *rDomain.KeywordRule) error {
+	rule.Word = strings.TrimSpace(rule.Word)
+	if rule.Word == "" {
+		return rDomain.ErrEmptyKeyword
+	}
+	if utf8.RuneCountInString(rule.Word) > rDomain.MaxKeywordLength {
+		return rDomain.ErrKeywordTooLong
+	}
+
+	if rule.Category == "" {
+		rule.Category = rDomain.KeywordCategoryProfanity
+	}
+	switch rule.Category {
+	case rDomain.KeywordCategoryProfanity, rDomain.KeywordCategorySpam, rDomain.KeywordCategoryAdvertising:
+	default:
+		return rDomain.ErrUnknownKeywordCategory
+	}
+
+	if rule.Severity == 0 {
+		rule.Severity = rDomain.KeywordSeverityReject
+	}
+	if rule.Severity < rDomain.KeywordSeverityMask || rule.Severity > rDomain.KeywordSeverityReject {
+		return rDomain.ErrUnknownKeywordSeverity
+	}
+
+	switch rule.Kind {
+	case rDomain.KeywordKindLiteral:
+		return nil
+	case rDomain.KeywordKindWildcard, rDomain.KeywordKindRegex:
+		_, err := compilePattern(rule)
+		return err
+	default:
+		return rDomain.ErrUnknownKeywordKind
+	}
+}
+
+func (r *keywordValidatorRepository) Match(ctx context.Context, text string) ([]*rDomain.KeywordMatch, error) {
+	snapshot, err := r.loadMatcher(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("matching keywords: %w", err)
+	}
+	return snapshot.match(text), nil
+}
+
+func (r *keywordValidatorRepository) ScanSalad(ctx context.Context, saladId uuid.UUID) ([]*rDomain.KeywordHit, error) {
+	snapshot, err := r.loadMatcher(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scanning salad: %w", err)
+	}
+
+	var salad rDomain.Salad
+	err = r.db.WithContext(ctx).
+		First(&salad, saladId).Error
+	if err != nil {
+		return nil, fmt.Errorf("scanning salad: %w", err)
+	}
+	var steps []*rDomain.RecipeStep
+	err = r.db.WithContext(ctx).
+		Joins("join saladRecipes.recipe on recipe.id = recipeStep.recipeId").
+		Where("recipe.saladId = ?", saladId).
+		Order("stepNum").
+		Find(&steps).Error
+	if err != nil {
+		return nil, fmt.Errorf("scanning salad (steps): %w", err)
+	}
+
+	hits := scanText(snapshot, rDomain.KeywordSourceSaladName, salad.ID, salad.Name)
+	for _, step := range steps {
+		hits = append(hits, scanText(snapshot, rDomain.KeywordSourceStepDescription, step.ID, step.Description)...)
+	}
+	return hits, nil
+}
+
+func (r *keywordValidatorRepository) ScanComment(ctx context.Context, commentId uuid.UUID) ([]*rDomain.KeywordHit, error) {
+	snapshot, err := r.loadMatcher(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scanning comment: %w", err)
+	}
+
+	var comment rDomain.Comment
+	err = r.db.WithContext(ctx).
+		First(&comment, commentId).Error
+	if err != nil {
+		return nil, fmt.Errorf("scanning comment: %w", err)
+	}
+	return scanText(snapshot, rDomain.KeywordSourceCommentText, comment.ID, comment.Text), nil
+}
+
+func scanText(snapshot *keywordSnapshot, source string, id uuid.UUID, text string) []*rDomain.KeywordHit {
+	matches := snapshot.match(text)
+	hits := make([]*rDomain.KeywordHit, 0, len(matches))
+	for _, match := range matches {
+		hits = append(hits, &rDomain.KeywordHit{
+			Source: source,
+			ID:     id,
+			Match:  match,
+		})
+	}
+	return hits
+}
+
+// loadMatcher returns the snapshot to match against, compiling it if the
+// matcher is not loaded. Callers keep matching against the returned snapshot,
+// an invalidation in the meantime only affects later loads. Under a steady
+// stream of changes the rules just read are used without being installed
+func (r *keywordValidatorRepository) loadMatcher(ctx context.Context) (*keywordSnapshot, error) {
+	for attempt := 1; ; attempt++ {
+		if snapshot := r.matcher.current(); snapshot != nil {
+			return snapshot, nil
+		}
+		generation := r.matcher.currentGeneration()
+
+		var rules []*rDomain.KeywordRule
+		err := r.db.WithContext(ctx).
+			Find(&rules).Error
+		if err != nil {
+			return nil, fmt.Errorf("loading keyword matcher: %w", err)
+		}
+
+		// a rule changed during the read may be missing from it, compile
+		// then refuses to install the rules and the loop reads them again
+		snapshot, installed, err := r.matcher.compile(rules, generation)
+		if err != nil {
+			return nil, fmt.Errorf("loading keyword matcher: %w", err)
+		}
+		if installed || attempt == maxCacheLoadAttempts {
+			return snapshot, nil
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+	"unicode/utf8"
+)
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) rDomain.ILoginAttemptRepository {
+	return &loginAttemptRepository{
+		db: db,
+	}
+}
+
+// Record stores the attempt. The login is whatever was typed, so it is cut to
+// the column size, an over-long login must not keep a failure from counting
+func (r *loginAttemptRepository) Record(ctx context.Context, attempt *rDomain.LoginAttempt) error {
+	dbAttempt := *attempt
+	dbAttempt.ID = idOrNew(attempt.ID)
+	if utf8.RuneCountInString(dbAttempt.Login) > rDomain.MaxAttemptLoginLength {
+		dbAttempt.Login = string([]rune(dbAttempt.Login)[:rDomain.MaxAttemptLoginLength])
+	}
+	if dbAttempt.CreatedAt.IsZero() {
+		dbAttempt.CreatedAt = time.Now()
+	}
+	err := createWithId(r.db.WithContext(ctx), &dbAttempt, dbAttempt.ID)
+	if err != nil {
+		return fmt.Errorf("recording login attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) CountUserFailures(ctx context.Context, userId uuid.UUID, window time.Duration) (int, error) {
+	count, err := r.countFailures(ctx, "userId", userId, window)
+	if err != nil {
+		return 0, fmt.Errorf("counting user login failures: %w", err)
+	}
+	return count, nil
+}
+
+func (r *loginAttemptRepository) CountIPFailures(ctx context.Context, ip string, window time.Duration) (int, error) {
+	count, err := r.countFailures(ctx, "ip", ip, window)
+	if err != nil {
+		return 0, fmt.Errorf("counting ip login failures: %w", err)
+	}
+	return count, nil
+}
+
+func (r *loginAttemptRepository) countFailures(ctx context.Context, column string, value interface{},
+	window time.Duration) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.LoginAttempt{}).
+		Where(column+" = ?", value).
+		Where("not success").
+		Where("createdAt >= ?", time.Now().Add(-window)).
+		Count(&count).Error
+	return int(count), err
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, userId uuid.UUID, until time.Time) error {
+	err := r.setLockedUntil(ctx, userId, sql.NullTime{Time: until, Valid: true})
+	if err != nil {
+		return fmt.Errorf("locking user: %w", err)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) Unlock(ctx context.Context, userId uuid.UUID) error {
+	err := r.setLockedUntil(ctx, userId, sql.NullTime{})
+	if err != nil {
+		return fmt.Errorf("unlocking user: %w", err)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) setLockedUntil(ctx context.Context, userId uuid.UUID, until sql.NullTime) error {
+	res := r.db.WithContext(ctx).
+		Model(&rDomain.User{}).
+		Where("id = ?", userId).
+		Update("lockedUntil", until)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		err := r.db.WithContext(ctx).
+			Model(&rDomain.User{}).
+			Where("id = ?", userId).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) GetLockout(ctx context.Context, userId uuid.UUID) (*rDomain.Lockout, error) {
+	var lockedUntil []sql.NullTime
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.User{}).
+		Where("id = ?", userId).
+		Pluck("lockedUntil", &lockedUntil).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting user lockout: %w", err)
+	}
+	if len(lockedUntil) == 0 {
+		return nil, fmt.Errorf("getting user lockout: %w", gorm.ErrRecordNotFound)
+	}
+
+	lockout := new(rDomain.Lockout)
+	if lockedUntil[0].Valid && lockedUntil[0].Time.After(time.Now()) {
+		lockout.Locked = true
+		lockout.LockedUntil = lockedUntil[0].Time
+	}
+	return lockout, nil
+}
+
+func (r *loginAttemptRepository) Cleanup(ctx context.Context, olderThan time.Time) (int, error) {
+	res := r.db.WithContext(ctx).
+		Where("createdAt < ?", olderThan).
+		Delete(&rDomain.LoginAttempt{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("cleaning up login attempts: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
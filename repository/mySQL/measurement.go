@@ -7,6 +7,7 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const PageSize = 30
@@ -21,15 +22,14 @@ func NewMeasrementRepository(db *gorm.DB) rDomain.IMeasurementRepository {
 	}
 }
 
-func (r *measurementRepository) Create(ctx context.Context, measurement *domain.Measurement) error {
+func (r *measurementRepository) Create(ctx context.Context, measurement *domain.Measurement) (uuid.UUID, error) {
 	dbMeasurement := rDomain.ToMeasurementDB(measurement)
-	dbMeasurement.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbMeasurement).Error
+	dbMeasurement.ID = idOrNew(measurement.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbMeasurement, dbMeasurement.ID)
 	if err != nil {
-		return fmt.Errorf("creating measurement: %w", err)
+		return uuid.Nil, fmt.Errorf("creating measurement: %w", err)
 	}
-	return nil
+	return dbMeasurement.ID, nil
 }
 
 func (r *measurementRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.Measurement, error) {
@@ -90,25 +90,146 @@ func (r *measurementRepository) Update(ctx context.Context, measurement *domain.
 }
 
 func (r *measurementRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Delete(&rDomain.Measurement{}, id).Error
+	return r.DeleteWithOptions(ctx, id, nil)
+}
+
+func (r *measurementRepository) DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *rDomain.DeleteOptions) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteWithUsages(tx, &rDomain.Measurement{}, id, opts, measurementUsages, replaceMeasurement)
+	})
 	if err != nil {
 		return fmt.Errorf("deleting measurement by id: %w", err)
 	}
 	return nil
 }
 
-func (r *measurementRepository) UpdateLink(ctx context.Context, linkId uuid.UUID, measurementId uuid.UUID, amount int) error {
+func (r *measurementRepository) UsageCount(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int64
 	err := r.db.WithContext(ctx).
-		Table("recipeIngredient").
-		Where("id = ?", linkId).
-		Updates(map[string]interface{}{
-			"measurement": measurementId,
-			"amount":      amount,
-		}).Error
+		Model(&rDomain.IngredientLink{}).
+		Where("measurement = ?", id).
+		Distinct("recipeId").
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting measurement usages: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *measurementRepository) GetUsages(ctx context.Context, id uuid.UUID) ([]*rDomain.Usage, error) {
+	usages, err := measurementUsages(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, fmt.Errorf("getting measurement usages: %w", err)
+	}
+	return usages, nil
+}
+
+func measurementUsages(db *gorm.DB, id uuid.UUID) ([]*rDomain.Usage, error) {
+	var usages []*rDomain.Usage
+	err := db.Table("recipeIngredient").
+		Distinct("? as kind, recipe.id as id, salad.name as name", rDomain.UsageKindRecipe).
+		Joins("join recipe on recipe.id = recipeIngredient.recipeId").
+		Joins("join salad on salad.id = recipe.saladId").
+		Where("recipeIngredient.measurement = ?", id).
+		Order("salad.name").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func (r *measurementRepository) UpdateLink(ctx context.Context, linkId uuid.UUID, measurementId uuid.UUID, amount int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var recipeIds []uuid.UUID
+		err := tx.Model(&rDomain.IngredientLink{}).
+			Where("id = ?", linkId).
+			Pluck("recipeId", &recipeIds).Error
+		if err != nil || len(recipeIds) == 0 {
+			return err
+		}
+		return withRecipeVersion(tx, recipeIds[0], func(tx *gorm.DB) error {
+			return tx.Table("recipeIngredient").
+				Where("id = ?", linkId).
+				Updates(map[string]interface{}{
+					"measurement": measurementId,
+					"amount":      amount,
+				}).Error
+		})
+	})
 
 	if err != nil {
 		return fmt.Errorf("updating measurement: %w", err)
 	}
 	return nil
 }
+
+// replaceMeasurement moves the recipe links from one unit to another and
+// converts their amounts, recording a version of every recipe it changes.
+// Units without a weight, or amounts that would round to nothing in the
+// replacement, are rDomain.ErrIncompatibleMeasurements
+func replaceMeasurement(tx *gorm.DB, id uuid.UUID, replacementId uuid.UUID) error {
+	var links []*rDomain.IngredientLink
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("measurement = ?", id).
+		Order("recipeId").
+		Find(&links).Error
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	var units []*struct {
+		ID    uuid.UUID `gorm:"column:id"`
+		Grams *int      `gorm:"column:grams"`
+	}
+	err = tx.Model(&rDomain.Measurement{}).
+		Select("id", "grams").
+		Where("id in ?", []uuid.UUID{id, replacementId}).
+		Scan(&units).Error
+	if err != nil {
+		return err
+	}
+	grams := make(map[uuid.UUID]int, len(units))
+	for _, unit := range units {
+		if unit.Grams == nil || *unit.Grams <= 0 {
+			return rDomain.ErrIncompatibleMeasurements
+		}
+		grams[unit.ID] = *unit.Grams
+	}
+
+	byRecipe := make(map[uuid.UUID][]*rDomain.IngredientLink)
+	recipeIds := make([]uuid.UUID, 0)
+	for _, link := range links {
+		amount := convertAmount(link.Amount, grams[id], grams[replacementId])
+		if amount == 0 && link.Amount != 0 {
+			return fmt.Errorf("%w: amount %d of link %s rounds to zero",
+				rDomain.ErrIncompatibleMeasurements, link.Amount, link.ID)
+		}
+		link.Amount = amount
+		if _, ok := byRecipe[link.RecipeId]; !ok {
+			recipeIds = append(recipeIds, link.RecipeId)
+		}
+		byRecipe[link.RecipeId] = append(byRecipe[link.RecipeId], link)
+	}
+
+	for _, recipeId := range recipeIds {
+		err = withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			for _, link := range byRecipe[recipeId] {
+				err := tx.Model(&rDomain.IngredientLink{}).
+					Where("id = ?", link.ID).
+					Updates(map[string]interface{}{
+						"measurement": replacementId,
+						"amount":      link.Amount,
+					}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
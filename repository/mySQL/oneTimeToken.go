@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+type oneTimeTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewOneTimeTokenRepository(db *gorm.DB) rDomain.IOneTimeTokenRepository {
+	return &oneTimeTokenRepository{
+		db: db,
+	}
+}
+
+// Create stores the token. An email verification token is bound to the email
+// the user has at this moment, VerifyEmail refuses it once the email changes
+func (r *oneTimeTokenRepository) Create(ctx context.Context, token *rDomain.OneTimeToken, plaintext string) (uuid.UUID, error) {
+	dbToken := *token
+	dbToken.ID = idOrNew(token.ID)
+	dbToken.TokenHash = hashToken(plaintext)
+	dbToken.EmailHash = nil
+	dbToken.CreatedAt = time.Now()
+	dbToken.ConsumedAt = nil
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if dbToken.Purpose == rDomain.TokenPurposeEmailVerification {
+			emailHash, err := userEmailHash(tx, dbToken.UserID, "SHARE")
+			if err != nil {
+				return err
+			}
+			dbToken.EmailHash = &emailHash
+		}
+		return createWithId(tx, &dbToken, dbToken.ID)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating one-time token: %w", err)
+	}
+	return dbToken.ID, nil
+}
+
+func (r *oneTimeTokenRepository) Consume(ctx context.Context, plaintext string, purpose string) (*rDomain.OneTimeToken, error) {
+	var token *rDomain.OneTimeToken
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = consumeToken(tx, plaintext, purpose)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consuming one-time token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *oneTimeTokenRepository) VerifyEmail(ctx context.Context, plaintext string) (uuid.UUID, error) {
+	var userId uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := consumeToken(tx, plaintext, rDomain.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		userId = token.UserID
+
+		emailHash, err := userEmailHash(tx, token.UserID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if token.EmailHash == nil || *token.EmailHash != emailHash {
+			return rDomain.ErrTokenEmail
+		}
+		return tx.Model(&rDomain.User{}).
+			Where("id = ?", token.UserID).
+			Update("emailVerified", true).Error
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("verifying email: %w", err)
+	}
+	return userId, nil
+}
+
+// userEmailHash locks the user row and hashes its email the way tokens store it
+func userEmailHash(tx *gorm.DB, userId uuid.UUID, lock string) (string, error) {
+	var emails []string
+	err := tx.Model(&rDomain.User{}).
+		Clauses(clause.Locking{Strength: lock}).
+		Where("id = ?", userId).
+		Pluck("email", &emails).Error
+	if err != nil {
+		return "", err
+	}
+	if len(emails) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return hashToken(strings.ToLower(strings.TrimSpace(emails[0]))), nil
+}
+
+// consumeToken marks the token as used with a single conditional update, so
+// of several concurrent callers only one sees the row changed
+func consumeToken(tx *gorm.DB, plaintext string, purpose string) (*rDomain.OneTimeToken, error) {
+	hash := hashToken(plaintext)
+	now := time.Now()
+	res := tx.Model(&rDomain.OneTimeToken{}).
+		Where("tokenHash = ?", hash).
+		Where("purpose = ?", purpose).
+		Where("consumedAt is null").
+		Where("expiresAt > ?", now).
+		Update("consumedAt", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	var token rDomain.OneTimeToken
+	err := tx.Where("tokenHash = ?", hash).
+		Where("purpose = ?", purpose).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, rDomain.ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		if token.ConsumedAt != nil {
+			return nil, rDomain.ErrTokenConsumed
+		}
+		return nil, rDomain.ErrTokenExpired
+	}
+	return &token, nil
+}
+
+func (r *oneTimeTokenRepository) PurgeExpired(ctx context.Context) (int, error) {
+	res := r.db.WithContext(ctx).
+		Where("expiresAt <= ?", time.Now()).
+		Delete(&rDomain.OneTimeToken{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("purging expired one-time tokens: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
@@ -21,9 +21,8 @@ func NewRecipeRepository(db *gorm.DB) rDomain.IRecipeRepository {
 
 func (r *recipeRepository) Create(ctx context.Context, recipe *domain.Recipe) (uuid.UUID, error) {
 	dbRecipe := rDomain.ToRecipeDB(recipe)
-	dbRecipe.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbRecipe).Error
+	dbRecipe.ID = idOrNew(recipe.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbRecipe, dbRecipe.ID)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("creating recipe: %w", err)
 	}
@@ -135,8 +134,11 @@ func (r *recipeRepository) GetAll(ctx context.Context, filter *domain.RecipeFilt
 
 func (r *recipeRepository) Update(ctx context.Context, recipe *domain.Recipe) error {
 	dbRecipe := rDomain.ToRecipeDB(recipe)
-	err := r.db.WithContext(ctx).
-		Save(&dbRecipe).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return withRecipeVersion(tx, dbRecipe.ID, func(tx *gorm.DB) error {
+			return tx.Save(&dbRecipe).Error
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("updating recipe: %w", err)
 	}
@@ -151,3 +153,175 @@ func (r *recipeRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+// totalTimeExpr is the total time of a recipe in minutes: the sum of explicit
+// prep, cook and rest times, else the sum of step timers, else timeToCook
+const totalTimeExpr = `coalesce(
+	nullif(recipe.prepMinutes + recipe.cookMinutes + recipe.restMinutes, 0),
+	nullif(ceil((select coalesce(sum(recipeStep.duration), 0)
+		from saladRecipes.recipeStep
+		where recipeStep.recipeId = recipe.id) / 60), 0),
+	recipe.timeToCook)`
+
+func (r *recipeRepository) GetDetails(ctx context.Context, recipeId uuid.UUID) (*rDomain.RecipeDetails, error) {
+	details, err := getRecipeDetails(r.db.WithContext(ctx), recipeId)
+	if err != nil {
+		return nil, fmt.Errorf("getting recipe details: %w", err)
+	}
+	return details, nil
+}
+
+func (r *recipeRepository) UpdateDetails(ctx context.Context, details *rDomain.RecipeDetails) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Select("id").
+			First(&rDomain.Recipe{}, details.ID).Error
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, details.ID, func(tx *gorm.DB) error {
+			res := tx.Model(&rDomain.RecipeDetails{}).
+				Where("id = ?", details.ID).
+				Updates(map[string]interface{}{
+					"prepMinutes": details.PrepMinutes,
+					"cookMinutes": details.CookMinutes,
+					"restMinutes": details.RestMinutes,
+					"difficulty":  details.Difficulty,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+
+			equipment := make([]uuid.UUID, 0, len(details.Equipment))
+			for _, item := range details.Equipment {
+				equipment = append(equipment, item.ID)
+			}
+			return setRecipeEquipment(tx, details.ID, equipment)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("updating recipe details: %w", err)
+	}
+	return nil
+}
+
+func (r *recipeRepository) GetAllEquipment(ctx context.Context) ([]*rDomain.Equipment, error) {
+	var equipment []*rDomain.Equipment
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Find(&equipment).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting equipment: %w", err)
+	}
+	return equipment, nil
+}
+
+func (r *recipeRepository) GetAllFiltered(ctx context.Context, filter *rDomain.RecipeListFilter, page int) ([]*domain.Recipe, int, error) {
+	query := func() *gorm.DB {
+		return applyRecipeFilter(r.db.WithContext(ctx).Model(&rDomain.Recipe{}), filter)
+	}
+
+	var dbRecipes []*rDomain.Recipe
+	err := query().
+		Order("recipe.rating desc").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Find(&dbRecipes).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting filtered recipes: %w", err)
+	}
+
+	recipes := make([]*domain.Recipe, 0)
+	for _, recipe := range dbRecipes {
+		recipes = append(recipes, rDomain.ToRecipeBL(recipe))
+	}
+
+	var count int64
+	err = query().
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting filtered recipes: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return recipes, int(numPages), nil
+}
+
+func getRecipeDetails(db *gorm.DB, recipeId uuid.UUID) (*rDomain.RecipeDetails, error) {
+	var details rDomain.RecipeDetails
+	err := db.Select("id", "prepMinutes", "cookMinutes", "restMinutes", "difficulty",
+		totalTimeExpr+" as totalMinutes").
+		First(&details, recipeId).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Table("equipment").
+		Select("equipment.id", "equipment.name").
+		Joins("join recipeEquipment on recipeEquipment.equipmentId = equipment.id").
+		Where("recipeEquipment.recipeId = ?", recipeId).
+		Order("equipment.name").
+		Scan(&details.Equipment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+func setRecipeEquipment(tx *gorm.DB, recipeId uuid.UUID, equipment []uuid.UUID) error {
+	err := tx.Where("recipeId = ?", recipeId).
+		Delete(&rDomain.EquipmentLink{}).Error
+	if err != nil {
+		return err
+	}
+	if len(equipment) == 0 {
+		return nil
+	}
+
+	links := make([]*rDomain.EquipmentLink, 0, len(equipment))
+	for _, id := range equipment {
+		links = append(links, &rDomain.EquipmentLink{
+			ID:          uuid.New(),
+			RecipeId:    recipeId,
+			EquipmentId: id,
+		})
+	}
+	return tx.Create(&links).Error
+}
+
+// applyRecipeFilter narrows a query that has the recipe table in scope
+func applyRecipeFilter(query *gorm.DB, filter *rDomain.RecipeListFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.Status != 0 {
+		query = query.Where("recipe.status = ?", filter.Status)
+	}
+	if filter.MinRate > 0 {
+		query = query.Where("recipe.rating >= ? or recipe.rating is null", filter.MinRate)
+	}
+	if len(filter.AvailableIngredients) != 0 {
+		query = query.Where(`not exists (select 1 from saladRecipes.recipeIngredient
+			where recipeIngredient.recipeId = recipe.id and recipeIngredient.ingredientId not in ?)`,
+			filter.AvailableIngredients)
+	}
+	if len(filter.SaladTypes) != 0 {
+		query = query.Where(`exists (select 1 from saladRecipes.typesOfSalads
+			where typesOfSalads.saladId = recipe.saladId and typesOfSalads.typeId in ?)`,
+			filter.SaladTypes)
+	}
+	if filter.MaxTotalMinutes > 0 {
+		query = query.Where(totalTimeExpr+" <= ?", filter.MaxTotalMinutes)
+	}
+	if len(filter.Difficulties) != 0 {
+		query = query.Where("recipe.difficulty in ?", filter.Difficulties)
+	}
+	if len(filter.ExcludedEquipment) != 0 {
+		query = query.Where(`not exists (select 1 from saladRecipes.recipeEquipment
+			where recipeEquipment.recipeId = recipe.id and recipeEquipment.equipmentId in ?)`,
+			filter.ExcludedEquipment)
+	}
+	return query
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"slices"
+	"strings"
+	"time"
 )
 
 type recipeStepRepository struct {
@@ -19,26 +22,28 @@ func NewRecipeStepRepository(db *gorm.DB) rDomain.IRecipeStepRepository {
 	}
 }
 
-func (r *recipeStepRepository) Create(ctx context.Context, recipeStep *domain.RecipeStep) error {
-	query := `insert into saladRecipes.recipeStep(name, description, recipeId, stepNum)
-	values (@name, @description, @recipe, 
-        (select freeStep from (select case
-			when max(stepNum) is null then 1
-			else max(stepNum) + 1
-			end as freeStep
-		from saladRecipes.recipeStep
-		where recipeId = @recipe) as tmp))`
+func (r *recipeStepRepository) Create(ctx context.Context, recipeStep *domain.RecipeStep) (uuid.UUID, error) {
+	dbStep := rDomain.ToStepDB(recipeStep)
+	dbStep.ID = idOrNew(recipeStep.ID)
 
-	err := r.db.WithContext(ctx).
-		Exec(query, map[string]interface{}{
-			"recipe":      recipeStep.RecipeID,
-			"name":        recipeStep.Name,
-			"description": recipeStep.Description,
-		}).Error
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		err := lockRecipe(tx, recipeStep.RecipeID)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeStep.RecipeID, func(tx *gorm.DB) error {
+			maxNum, err := maxStepNum(tx, recipeStep.RecipeID)
+			if err != nil {
+				return err
+			}
+			dbStep.StepNum = maxNum + 1
+			return createWithId(tx, &dbStep, dbStep.ID)
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("creating recipe step: %w", err)
+		return uuid.Nil, fmt.Errorf("creating recipe step: %w", err)
 	}
-	return nil
+	return dbStep.ID, nil
 }
 
 func (r *recipeStepRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.RecipeStep, error) {
@@ -70,147 +75,371 @@ func (r *recipeStepRepository) GetAllByRecipeID(ctx context.Context, recipeId uu
 }
 
 func (r *recipeStepRepository) Update(ctx context.Context, recipeStep *domain.RecipeStep) error {
-	tx := r.db.WithContext(ctx).
-		Begin()
-	tx.SavePoint("rollbackPoint")
-	err := tx.Error
-	defer func() {
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		err := lockRecipe(tx, recipeStep.RecipeID)
 		if err != nil {
-			rollbackErr := tx.RollbackTo("rollbackPoint").Error
-			if rollbackErr != nil {
-				err = fmt.Errorf("%v: %w", rollbackErr, err)
-			}
+			return err
 		}
-	}()
 
-	type maxRes struct {
-		maxNum  int
-		stepNum int
-	}
-	var res maxRes
+		var current rDomain.RecipeStep
+		err = tx.Where("recipeId = ?", recipeStep.RecipeID).
+			First(&current, recipeStep.ID).Error
+		if err != nil {
+			return err
+		}
+		maxNum, err := maxStepNum(tx, recipeStep.RecipeID)
+		if err != nil {
+			return fmt.Errorf("checking max step num: %w", err)
+		}
+		if recipeStep.StepNum < 1 || recipeStep.StepNum > maxNum {
+			return rDomain.ErrStepOutOfRange
+		}
 
-	err = tx.WithContext(context.Background()).
-		Table("recipeStep").
-		Where("recipeId = ?", recipeStep.RecipeID).
-		Select("case when max(stepNum) is null then 0 else max(stepNum) end as maxNum").
-		Scan(&res.maxNum).Error
+		return withRecipeVersion(tx, recipeStep.RecipeID, func(tx *gorm.DB) error {
+			err := moveStep(tx, &current, recipeStep.StepNum, maxNum)
+			if err != nil {
+				return fmt.Errorf("moving other steps: %w", err)
+			}
+			return tx.Model(&rDomain.RecipeStep{}).
+				Where("id = ?", recipeStep.ID).
+				Updates(map[string]interface{}{
+					"name":        recipeStep.Name,
+					"description": recipeStep.Description,
+				}).Error
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("updating recipe step (checking max step num): %w", err)
+		return fmt.Errorf("updating recipe step: %w", err)
 	}
+	return nil
+}
 
-	err = tx.WithContext(context.Background()).
-		Table("recipeStep").
-		Where("id = ?", recipeStep.ID).
-		Select("stepNum").
-		Scan(&res.stepNum).Error
-	if err != nil {
-		return fmt.Errorf("updating recipe step (checking max step num): %w", err)
-	}
-	if recipeStep.StepNum > res.maxNum {
-		return fmt.Errorf("updating recipe step: step num out of range")
-	}
+func (r *recipeStepRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		var recipeIds []uuid.UUID
+		err := tx.Model(&rDomain.RecipeStep{}).
+			Where("id = ?", id).
+			Pluck("recipeId", &recipeIds).Error
+		if err != nil || len(recipeIds) == 0 {
+			return err
+		}
+		err = lockRecipe(tx, recipeIds[0])
+		if err != nil {
+			return err
+		}
 
-	if recipeStep.StepNum < res.stepNum {
-		err = tx.WithContext(ctx).
-			Table("recipeStep").
-			Where("recipeId = ?", recipeStep.RecipeID).
-			Where("stepNum between ? and ?", recipeStep.StepNum, res.stepNum-1).
-			Update("stepNum", gorm.Expr("stepNum + 1")).Error
-	} else {
-		err = tx.WithContext(ctx).
-			Table("recipeStep").
-			Where("recipeId = ?", recipeStep.RecipeID).
-			Where("stepNum between ? and ?", res.stepNum+1, recipeStep.StepNum).
-			Update("stepNum", gorm.Expr("stepNum - 1")).Error
-	}
-	if err != nil {
-		return fmt.Errorf("updating recipe step (moving other steps): %w", err)
-	}
-
-	query := `update saladRecipes.recipeStep
-		set
-			name = @name,
-			description = @description,
-			stepNum = @stepNum
-		where id = @id`
-	err = tx.WithContext(ctx).
-		Exec(query, map[string]interface{}{
-			"name":        recipeStep.Name,
-			"description": recipeStep.Description,
-			"stepNum":     recipeStep.StepNum,
-			"id":          recipeStep.ID,
-		}).Error
+		return withRecipeVersion(tx, recipeIds[0], func(tx *gorm.DB) error {
+			var step rDomain.RecipeStep
+			res := tx.Limit(1).Find(&step, id)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+
+			err := tx.Delete(&rDomain.RecipeStep{}, id).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(`update saladRecipes.recipeStep
+				set stepNum = stepNum - 1
+				where recipeId = ? and stepNum > ?
+				order by stepNum`, step.RecipeID, step.StepNum).Error
+			if err != nil {
+				return fmt.Errorf("moving other steps: %w", err)
+			}
+			return nil
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("updating recipe step: %w", err)
+		return fmt.Errorf("deleting recipe step by id: %w", err)
 	}
+	return nil
+}
+
+func (r *recipeStepRepository) DeleteAllByRecipeID(ctx context.Context, recipeId uuid.UUID) error {
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		err := lockRecipe(tx, recipeId)
+		if err != nil {
+			return err
+		}
 
-	err = tx.Commit().Error
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Where("recipeId = ?", recipeId).
+				Delete(&rDomain.RecipeStep{}).Error
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("updating recipe step (commiting transaction): %w", err)
+		return fmt.Errorf("deleting all recipe steps by id: %w", err)
 	}
 	return nil
 }
 
-func (r *recipeStepRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
-	tx := r.db.WithContext(ctx).
-		Begin()
-	tx.SavePoint("rollbackPoint")
-	err := tx.Error
-	defer func() {
+func (r *recipeStepRepository) Reorder(ctx context.Context, recipeId uuid.UUID, orderedStepIds []uuid.UUID) error {
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		err := lockRecipe(tx, recipeId)
+		if err != nil {
+			return err
+		}
+
+		var stepIds []uuid.UUID
+		err = tx.Model(&rDomain.RecipeStep{}).
+			Where("recipeId = ?", recipeId).
+			Pluck("id", &stepIds).Error
 		if err != nil {
-			rollbackErr := tx.RollbackTo("rollbackPoint").Error
-			if rollbackErr != nil {
-				err = fmt.Errorf("%v: %w", rollbackErr, err)
+			return err
+		}
+		if len(stepIds) != len(orderedStepIds) {
+			return rDomain.ErrInvalidStepOrder
+		}
+		seen := make(map[uuid.UUID]bool, len(orderedStepIds))
+		for _, id := range orderedStepIds {
+			if seen[id] || !slices.Contains(stepIds, id) {
+				return rDomain.ErrInvalidStepOrder
 			}
+			seen[id] = true
 		}
-	}()
 
-	type queryRes struct {
-		recipeId uuid.UUID
-		stepNum  int
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			err := tx.Exec(`update saladRecipes.recipeStep
+				set stepNum = stepNum + ?
+				where recipeId = ?
+				order by stepNum desc`, len(stepIds), recipeId).Error
+			if err != nil {
+				return fmt.Errorf("moving steps: %w", err)
+			}
+			for i, id := range orderedStepIds {
+				err = tx.Model(&rDomain.RecipeStep{}).
+					Where("id = ?", id).
+					Update("stepNum", i+1).Error
+				if err != nil {
+					return fmt.Errorf("moving steps: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("reordering recipe steps: %w", err)
 	}
-	var res queryRes
+	return nil
+}
 
-	var dbStep rDomain.RecipeStep
-	err = tx.WithContext(ctx).
-		Find(&dbStep, id).Error
-	res.stepNum = dbStep.StepNum
-	res.recipeId = dbStep.RecipeID
+func (r *recipeStepRepository) InsertAt(ctx context.Context, recipeStep *domain.RecipeStep, position int) (uuid.UUID, error) {
+	dbStep := rDomain.ToStepDB(recipeStep)
+	dbStep.ID = idOrNew(recipeStep.ID)
+	dbStep.StepNum = position
+
+	err := transactionWithRetry(ctx, r.db, func(tx *gorm.DB) error {
+		err := lockRecipe(tx, recipeStep.RecipeID)
+		if err != nil {
+			return err
+		}
+		maxNum, err := maxStepNum(tx, recipeStep.RecipeID)
+		if err != nil {
+			return err
+		}
+		if position < 1 || position > maxNum+1 {
+			return rDomain.ErrStepOutOfRange
+		}
 
+		return withRecipeVersion(tx, recipeStep.RecipeID, func(tx *gorm.DB) error {
+			err := tx.Exec(`update saladRecipes.recipeStep
+				set stepNum = stepNum + 1
+				where recipeId = ? and stepNum >= ?
+				order by stepNum desc`, recipeStep.RecipeID, position).Error
+			if err != nil {
+				return fmt.Errorf("moving other steps: %w", err)
+			}
+			return createWithId(tx, &dbStep, dbStep.ID)
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("deleting recipe step by id (getting recipe ID): %w", err)
+		return uuid.Nil, fmt.Errorf("inserting recipe step: %w", err)
 	}
+	return dbStep.ID, nil
+}
 
-	fmt.Println("!!!!!RES!!!!!", res.recipeId, res.stepNum)
-
-	err = tx.WithContext(ctx).
-		Table("recipeStep").
-		Where("recipeId = ?", res.recipeId).
-		Where("stepNum > ?", res.stepNum).
-		Update("stepNum", gorm.Expr("stepNum - 1")).Error
-
+func (r *recipeStepRepository) UpdateDetails(ctx context.Context, stepId uuid.UUID, details *rDomain.RecipeStepDetails) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdByStep(tx, stepId)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Model(&rDomain.RecipeStep{}).
+				Where("id = ?", stepId).
+				Updates(map[string]interface{}{
+					"section":    strings.TrimSpace(details.Section),
+					"duration":   int(details.Duration / time.Second),
+					"timerLabel": details.TimerLabel,
+				}).Error
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("updating recipe step (moving other steps): %w", err)
+		return fmt.Errorf("updating recipe step details: %w", err)
 	}
-	err = tx.WithContext(ctx).
-		Delete(&rDomain.RecipeStep{}, id).Error
+	return nil
+}
+
+func (r *recipeStepRepository) LinkIngredient(ctx context.Context, stepId uuid.UUID, linkId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdByStep(tx, stepId)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		err = tx.Model(&rDomain.IngredientLink{}).
+			Where("id = ?", linkId).
+			Where("recipeId = ?", recipeId).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return rDomain.ErrForeignRecipeLink
+		}
+
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Create(&rDomain.StepIngredientLink{
+				ID:     uuid.New(),
+				StepId: stepId,
+				LinkId: linkId,
+			}).Error
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("deleting recipe step by id: %w", err)
+		return fmt.Errorf("linking ingredient to recipe step: %w", err)
 	}
+	return nil
+}
 
-	err = tx.Commit().Error
+func (r *recipeStepRepository) UnlinkIngredient(ctx context.Context, stepId uuid.UUID, linkId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdByStep(tx, stepId)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Where("stepId = ?", stepId).
+				Where("linkId = ?", linkId).
+				Delete(&rDomain.StepIngredientLink{}).Error
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("deleting recipe step (commiting transaction): %w", err)
+		return fmt.Errorf("unlinking ingredient from recipe step: %w", err)
 	}
 	return nil
 }
 
-func (r *recipeStepRepository) DeleteAllByRecipeID(ctx context.Context, recipeId uuid.UUID) error {
+func (r *recipeStepRepository) GetSections(ctx context.Context, recipeId uuid.UUID) ([]*rDomain.RecipeSection, error) {
+	var dbSteps []*rDomain.RecipeStep
 	err := r.db.WithContext(ctx).
 		Where("recipeId = ?", recipeId).
-		Delete(&rDomain.RecipeStep{}).Error
+		Order("stepNum").
+		Find(&dbSteps).Error
 	if err != nil {
-		return fmt.Errorf("deleting all recipe steps by id: %w", err)
+		return nil, fmt.Errorf("getting recipe sections: %w", err)
 	}
-	return nil
+
+	type stepIngredient struct {
+		rDomain.StepIngredient
+		StepId uuid.UUID `gorm:"column:stepId"`
+	}
+	var ingredients []*stepIngredient
+	err = r.db.WithContext(ctx).
+		Table("stepIngredient").
+		Select("stepIngredient.stepId", "stepIngredient.linkId", "recipeIngredient.ingredientId",
+			"ingredient.name", "recipeIngredient.measurement", "recipeIngredient.amount").
+		Joins("join recipeIngredient on recipeIngredient.id = stepIngredient.linkId").
+		Joins("join ingredient on ingredient.id = recipeIngredient.ingredientId").
+		Where("recipeIngredient.recipeId = ?", recipeId).
+		Order("ingredient.name").
+		Scan(&ingredients).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting recipe sections (ingredients): %w", err)
+	}
+	byStep := make(map[uuid.UUID][]*rDomain.StepIngredient)
+	for _, ingredient := range ingredients {
+		byStep[ingredient.StepId] = append(byStep[ingredient.StepId], &ingredient.StepIngredient)
+	}
+
+	sections := make([]*rDomain.RecipeSection, 0)
+	for _, step := range dbSteps {
+		if len(sections) == 0 || sections[len(sections)-1].Name != step.Section {
+			sections = append(sections, &rDomain.RecipeSection{
+				Name:  step.Section,
+				Steps: make([]*rDomain.SectionStep, 0),
+			})
+		}
+		stepIngredients := byStep[step.ID]
+		if stepIngredients == nil {
+			stepIngredients = make([]*rDomain.StepIngredient, 0)
+		}
+
+		section := sections[len(sections)-1]
+		section.Steps = append(section.Steps, &rDomain.SectionStep{
+			Step: rDomain.ToStepBL(step),
+			Details: rDomain.RecipeStepDetails{
+				Section:    step.Section,
+				Duration:   time.Duration(step.Duration) * time.Second,
+				TimerLabel: step.TimerLabel,
+			},
+			Ingredients: stepIngredients,
+		})
+	}
+	return sections, nil
+}
+
+func recipeIdByStep(tx *gorm.DB, stepId uuid.UUID) (uuid.UUID, error) {
+	var step rDomain.RecipeStep
+	err := tx.Select("id", "recipeId").
+		First(&step, stepId).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return step.RecipeID, nil
+}
+
+func maxStepNum(tx *gorm.DB, recipeId uuid.UUID) (int, error) {
+	var maxNum int
+	err := tx.Model(&rDomain.RecipeStep{}).
+		Where("recipeId = ?", recipeId).
+		Select("coalesce(max(stepNum), 0)").
+		Scan(&maxNum).Error
+	return maxNum, err
+}
+
+// moveStep renumbers the steps of a recipe so that step lands on stepNum.
+// The step is parked after the last one first and the others are shifted
+// in an order that keeps (recipeId, stepNum) unique on every row update
+func moveStep(tx *gorm.DB, step *rDomain.RecipeStep, stepNum int, maxNum int) error {
+	if step.StepNum == stepNum {
+		return nil
+	}
+
+	err := tx.Model(&rDomain.RecipeStep{}).
+		Where("id = ?", step.ID).
+		Update("stepNum", maxNum+1).Error
+	if err != nil {
+		return err
+	}
+
+	if stepNum < step.StepNum {
+		err = tx.Exec(`update saladRecipes.recipeStep
+			set stepNum = stepNum + 1
+			where recipeId = ? and stepNum between ? and ?
+			order by stepNum desc`, step.RecipeID, stepNum, step.StepNum-1).Error
+	} else {
+		err = tx.Exec(`update saladRecipes.recipeStep
+			set stepNum = stepNum - 1
+			where recipeId = ? and stepNum between ? and ?
+			order by stepNum`, step.RecipeID, step.StepNum+1, stepNum).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&rDomain.RecipeStep{}).
+		Where("id = ?", step.ID).
+		Update("stepNum", stepNum).Error
 }
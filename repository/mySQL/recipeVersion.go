@@ -0,0 +1,535 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type recipeVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewRecipeVersionRepository(db *gorm.DB) rDomain.IRecipeVersionRepository {
+	return &recipeVersionRepository{
+		db: db,
+	}
+}
+
+func (r *recipeVersionRepository) GetAll(ctx context.Context, recipeId uuid.UUID) ([]*rDomain.RecipeVersion, error) {
+	var versions []*rDomain.RecipeVersion
+	err := r.db.WithContext(ctx).
+		Select("id", "recipeId", "version", "createdAt").
+		Where("recipeId = ?", recipeId).
+		Order("version").
+		Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting recipe versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *recipeVersionRepository) GetByVersion(ctx context.Context, recipeId uuid.UUID, version int) (*rDomain.RecipeVersion, error) {
+	res, err := getRecipeVersion(r.db.WithContext(ctx), recipeId, version)
+	if err != nil {
+		return nil, fmt.Errorf("getting recipe version: %w", err)
+	}
+	return res, nil
+}
+
+func (r *recipeVersionRepository) Diff(ctx context.Context, recipeId uuid.UUID, from int, to int) (*rDomain.RecipeDiff, error) {
+	fromVersion, err := getRecipeVersion(r.db.WithContext(ctx), recipeId, from)
+	if err != nil {
+		return nil, fmt.Errorf("diffing recipe versions: %w", err)
+	}
+	toVersion, err := getRecipeVersion(r.db.WithContext(ctx), recipeId, to)
+	if err != nil {
+		return nil, fmt.Errorf("diffing recipe versions: %w", err)
+	}
+
+	diff := diffSnapshots(fromVersion.Snapshot, toVersion.Snapshot)
+	diff.From = from
+	diff.To = to
+	return diff, nil
+}
+
+func (r *recipeVersionRepository) Revert(ctx context.Context, recipeId uuid.UUID, version int) (int, error) {
+	var newVersion int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		target, err := getRecipeVersion(tx, recipeId, version)
+		if err != nil {
+			return err
+		}
+		err = checkSnapshotReferences(tx, target.Snapshot)
+		if err != nil {
+			return err
+		}
+
+		err = withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return applyRecipeSnapshot(tx, recipeId, target.Snapshot)
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&rDomain.RecipeVersion{}).
+			Where("recipeId = ?", recipeId).
+			Select("max(version)").
+			Scan(&newVersion).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reverting recipe to version %d: %w", version, err)
+	}
+	return newVersion, nil
+}
+
+func getRecipeVersion(db *gorm.DB, recipeId uuid.UUID, version int) (*rDomain.RecipeVersion, error) {
+	var res rDomain.RecipeVersion
+	err := db.Where("recipeId = ?", recipeId).
+		Where("version = ?", version).
+		First(&res).Error
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// withRecipeVersion snapshots the recipe before and after change, so the
+// content that change overwrites is kept even if it was never recorded
+func withRecipeVersion(tx *gorm.DB, recipeId uuid.UUID, change func(tx *gorm.DB) error) error {
+	err := recordRecipeVersion(tx, recipeId)
+	if err != nil {
+		return fmt.Errorf("recording recipe version: %w", err)
+	}
+	err = change(tx)
+	if err != nil {
+		return err
+	}
+	err = recordRecipeVersion(tx, recipeId)
+	if err != nil {
+		return fmt.Errorf("recording recipe version: %w", err)
+	}
+	return nil
+}
+
+func recipeIdBySalad(db *gorm.DB, saladId uuid.UUID) (uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.Model(&rDomain.Recipe{}).
+		Where("saladId = ?", saladId).
+		Limit(1).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return uuid.Nil, err
+	}
+	return ids[0], nil
+}
+
+func recordRecipeVersion(tx *gorm.DB, recipeId uuid.UUID) error {
+	var recipe rDomain.Recipe
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Limit(1).
+		Find(&recipe, recipeId)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	snapshot, err := takeRecipeSnapshot(tx, &recipe)
+	if err != nil {
+		return err
+	}
+
+	var last rDomain.RecipeVersion
+	res = tx.Where("recipeId = ?", recipeId).
+		Order("version desc").
+		Limit(1).
+		Find(&last)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 0 {
+		lastJson, _ := json.Marshal(last.Snapshot)
+		curJson, _ := json.Marshal(snapshot)
+		if bytes.Equal(lastJson, curJson) {
+			return nil
+		}
+	}
+
+	return tx.Create(&rDomain.RecipeVersion{
+		ID:        uuid.New(),
+		RecipeID:  recipeId,
+		Version:   last.Version + 1,
+		CreatedAt: time.Now(),
+		Snapshot:  snapshot,
+	}).Error
+}
+
+func takeRecipeSnapshot(tx *gorm.DB, recipe *rDomain.Recipe) (*rDomain.RecipeSnapshot, error) {
+	var salad rDomain.Salad
+	err := tx.First(&salad, recipe.SaladID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []*rDomain.RecipeStep
+	err = tx.Where("recipeId = ?", recipe.ID).
+		Order("stepNum").
+		Find(&steps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var links []*rDomain.IngredientLink
+	err = tx.Where("recipeId = ?", recipe.ID).
+		Order("ingredientId").
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var stepLinks []*rDomain.StepIngredientLink
+	err = tx.Joins("join recipeStep on recipeStep.id = stepIngredient.stepId").
+		Where("recipeStep.recipeId = ?", recipe.ID).
+		Order("stepIngredient.linkId").
+		Find(&stepLinks).Error
+	if err != nil {
+		return nil, err
+	}
+	linksByStep := make(map[uuid.UUID][]uuid.UUID)
+	for _, link := range stepLinks {
+		linksByStep[link.StepId] = append(linksByStep[link.StepId], link.LinkId)
+	}
+
+	details, err := getRecipeDetails(tx, recipe.ID)
+	if err != nil {
+		return nil, err
+	}
+	equipment := make([]uuid.UUID, 0, len(details.Equipment))
+	for _, item := range details.Equipment {
+		equipment = append(equipment, item.ID)
+	}
+
+	types := make([]uuid.UUID, 0)
+	err = tx.Model(&rDomain.TypeLink{}).
+		Where("saladId = ?", recipe.SaladID).
+		Order("typeId").
+		Pluck("typeId", &types).Error
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &rDomain.RecipeSnapshot{
+		Salad: rDomain.SaladSnapshot{
+			AuthorID:    salad.AuthorID,
+			Name:        salad.Name,
+			Description: salad.Description,
+		},
+		Recipe: rDomain.RecipeFieldsSnapshot{
+			Status:           recipe.Status,
+			NumberOfServings: recipe.NumberOfServings,
+			TimeToCook:       recipe.TimeToCook,
+			PrepMinutes:      details.PrepMinutes,
+			CookMinutes:      details.CookMinutes,
+			RestMinutes:      details.RestMinutes,
+			Difficulty:       details.Difficulty,
+			Equipment:        equipment,
+		},
+		Steps:       make([]*rDomain.StepSnapshot, 0, len(steps)),
+		Ingredients: make([]*rDomain.IngredientLinkSnapshot, 0, len(links)),
+		Types:       types,
+	}
+	for _, step := range steps {
+		linkIds := linksByStep[step.ID]
+		if linkIds == nil {
+			linkIds = make([]uuid.UUID, 0)
+		}
+		snapshot.Steps = append(snapshot.Steps, &rDomain.StepSnapshot{
+			ID:          step.ID,
+			Name:        step.Name,
+			Description: step.Description,
+			StepNum:     step.StepNum,
+			Section:     step.Section,
+			Duration:    step.Duration,
+			TimerLabel:  step.TimerLabel,
+			LinkIds:     linkIds,
+		})
+	}
+	for _, link := range links {
+		snapshot.Ingredients = append(snapshot.Ingredients, &rDomain.IngredientLinkSnapshot{
+			ID:           link.ID,
+			IngredientId: link.IngredientId,
+			Measurement:  link.Measurement,
+			Amount:       link.Amount,
+		})
+	}
+	return snapshot, nil
+}
+
+// checkSnapshotReferences makes sure the rows the snapshot links to still
+// exist, the share lock keeps them from being deleted until the revert commits
+func checkSnapshotReferences(tx *gorm.DB, snapshot *rDomain.RecipeSnapshot) error {
+	ingredients := make([]uuid.UUID, 0, len(snapshot.Ingredients))
+	measurements := make([]uuid.UUID, 0, len(snapshot.Ingredients))
+	for _, link := range snapshot.Ingredients {
+		ingredients = append(ingredients, link.IngredientId)
+		measurements = append(measurements, link.Measurement)
+	}
+
+	for _, ref := range []struct {
+		name  string
+		model interface{}
+		ids   []uuid.UUID
+	}{
+		{"ingredient", &rDomain.Ingredient{}, ingredients},
+		{"measurement", &rDomain.Measurement{}, measurements},
+		{"equipment", &rDomain.Equipment{}, snapshot.Recipe.Equipment},
+		{"salad type", &rDomain.SaladType{}, snapshot.Types},
+	} {
+		if len(ref.ids) == 0 {
+			continue
+		}
+		var found []uuid.UUID
+		err := tx.Model(ref.model).
+			Clauses(clause.Locking{Strength: "SHARE"}).
+			Where("id in ?", ref.ids).
+			Pluck("id", &found).Error
+		if err != nil {
+			return fmt.Errorf("checking %s references: %w", ref.name, err)
+		}
+		for _, id := range ref.ids {
+			if !slices.Contains(found, id) {
+				return fmt.Errorf("%w: %s %s", rDomain.ErrVersionReferencesDeleted, ref.name, id)
+			}
+		}
+	}
+	return nil
+}
+
+// applyRecipeSnapshot restores the content of the recipe. The moderation
+// status is left as is, reverting content does not republish a recipe
+func applyRecipeSnapshot(tx *gorm.DB, recipeId uuid.UUID, snapshot *rDomain.RecipeSnapshot) error {
+	var recipe rDomain.Recipe
+	err := tx.First(&recipe, recipeId).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&rDomain.Salad{}).
+		Where("id = ?", recipe.SaladID).
+		Updates(map[string]interface{}{
+			"name":        snapshot.Salad.Name,
+			"description": snapshot.Salad.Description,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("restoring salad: %w", err)
+	}
+
+	err = tx.Model(&rDomain.Recipe{}).
+		Where("id = ?", recipeId).
+		Updates(map[string]interface{}{
+			"numberOfServings": snapshot.Recipe.NumberOfServings,
+			"timeToCook":       snapshot.Recipe.TimeToCook,
+			"prepMinutes":      snapshot.Recipe.PrepMinutes,
+			"cookMinutes":      snapshot.Recipe.CookMinutes,
+			"restMinutes":      snapshot.Recipe.RestMinutes,
+			"difficulty":       snapshot.Recipe.Difficulty,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("restoring recipe: %w", err)
+	}
+	err = setRecipeEquipment(tx, recipeId, snapshot.Recipe.Equipment)
+	if err != nil {
+		return fmt.Errorf("restoring equipment: %w", err)
+	}
+
+	err = tx.Where("recipeId = ?", recipeId).
+		Delete(&rDomain.RecipeStep{}).Error
+	if err != nil {
+		return fmt.Errorf("restoring steps: %w", err)
+	}
+	if len(snapshot.Steps) != 0 {
+		steps := make([]*rDomain.RecipeStep, 0, len(snapshot.Steps))
+		for _, step := range snapshot.Steps {
+			steps = append(steps, &rDomain.RecipeStep{
+				ID:          step.ID,
+				RecipeID:    recipeId,
+				Name:        step.Name,
+				Description: step.Description,
+				StepNum:     step.StepNum,
+				Section:     step.Section,
+				Duration:    step.Duration,
+				TimerLabel:  step.TimerLabel,
+			})
+		}
+		err = tx.Create(&steps).Error
+		if err != nil {
+			return fmt.Errorf("restoring steps: %w", err)
+		}
+	}
+
+	err = tx.Where("recipeId = ?", recipeId).
+		Delete(&rDomain.IngredientLink{}).Error
+	if err != nil {
+		return fmt.Errorf("restoring ingredients: %w", err)
+	}
+	if len(snapshot.Ingredients) != 0 {
+		links := make([]*rDomain.IngredientLink, 0, len(snapshot.Ingredients))
+		for _, link := range snapshot.Ingredients {
+			links = append(links, &rDomain.IngredientLink{
+				ID:           link.ID,
+				RecipeId:     recipeId,
+				IngredientId: link.IngredientId,
+				Measurement:  link.Measurement,
+				Amount:       link.Amount,
+			})
+		}
+		err = tx.Create(&links).Error
+		if err != nil {
+			return fmt.Errorf("restoring ingredients: %w", err)
+		}
+	}
+
+	stepLinks := make([]*rDomain.StepIngredientLink, 0)
+	for _, step := range snapshot.Steps {
+		for _, linkId := range step.LinkIds {
+			stepLinks = append(stepLinks, &rDomain.StepIngredientLink{
+				ID:     uuid.New(),
+				StepId: step.ID,
+				LinkId: linkId,
+			})
+		}
+	}
+	if len(stepLinks) != 0 {
+		err = tx.Create(&stepLinks).Error
+		if err != nil {
+			return fmt.Errorf("restoring step ingredients: %w", err)
+		}
+	}
+
+	err = tx.Where("saladId = ?", recipe.SaladID).
+		Delete(&rDomain.TypeLink{}).Error
+	if err != nil {
+		return fmt.Errorf("restoring types: %w", err)
+	}
+	if len(snapshot.Types) != 0 {
+		types := make([]*rDomain.TypeLink, 0, len(snapshot.Types))
+		for _, typeId := range snapshot.Types {
+			types = append(types, &rDomain.TypeLink{
+				ID:      uuid.New(),
+				SaladId: recipe.SaladID,
+				TypeId:  typeId,
+			})
+		}
+		err = tx.Create(&types).Error
+		if err != nil {
+			return fmt.Errorf("restoring types: %w", err)
+		}
+	}
+	return nil
+}
+
+func diffSnapshots(from *rDomain.RecipeSnapshot, to *rDomain.RecipeSnapshot) *rDomain.RecipeDiff {
+	diff := &rDomain.RecipeDiff{
+		Fields:             make([]*rDomain.FieldChange, 0),
+		AddedSteps:         make([]*rDomain.StepSnapshot, 0),
+		RemovedSteps:       make([]*rDomain.StepSnapshot, 0),
+		ChangedSteps:       make([]*rDomain.StepChange, 0),
+		AddedIngredients:   make([]*rDomain.IngredientLinkSnapshot, 0),
+		RemovedIngredients: make([]*rDomain.IngredientLinkSnapshot, 0),
+		ChangedIngredients: make([]*rDomain.IngredientLinkChange, 0),
+		AddedTypes:         make([]uuid.UUID, 0),
+		RemovedTypes:       make([]uuid.UUID, 0),
+	}
+
+	addField := func(field string, old string, new string) {
+		if old != new {
+			diff.Fields = append(diff.Fields, &rDomain.FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	addField("salad.name", from.Salad.Name, to.Salad.Name)
+	addField("salad.description", from.Salad.Description, to.Salad.Description)
+	addField("recipe.status", strconv.Itoa(from.Recipe.Status), strconv.Itoa(to.Recipe.Status))
+	addField("recipe.numberOfServings",
+		strconv.Itoa(from.Recipe.NumberOfServings), strconv.Itoa(to.Recipe.NumberOfServings))
+	addField("recipe.timeToCook", strconv.Itoa(from.Recipe.TimeToCook), strconv.Itoa(to.Recipe.TimeToCook))
+	addField("recipe.prepMinutes", strconv.Itoa(from.Recipe.PrepMinutes), strconv.Itoa(to.Recipe.PrepMinutes))
+	addField("recipe.cookMinutes", strconv.Itoa(from.Recipe.CookMinutes), strconv.Itoa(to.Recipe.CookMinutes))
+	addField("recipe.restMinutes", strconv.Itoa(from.Recipe.RestMinutes), strconv.Itoa(to.Recipe.RestMinutes))
+	addField("recipe.difficulty", strconv.Itoa(from.Recipe.Difficulty), strconv.Itoa(to.Recipe.Difficulty))
+	addField("recipe.equipment", fmt.Sprint(from.Recipe.Equipment), fmt.Sprint(to.Recipe.Equipment))
+
+	oldSteps := make(map[uuid.UUID]*rDomain.StepSnapshot, len(from.Steps))
+	for _, step := range from.Steps {
+		oldSteps[step.ID] = step
+	}
+	for _, step := range to.Steps {
+		old, ok := oldSteps[step.ID]
+		if !ok {
+			diff.AddedSteps = append(diff.AddedSteps, step)
+			continue
+		}
+		delete(oldSteps, step.ID)
+		if stepChanged(old, step) {
+			diff.ChangedSteps = append(diff.ChangedSteps, &rDomain.StepChange{Old: old, New: step})
+		}
+	}
+	for _, step := range from.Steps {
+		if _, ok := oldSteps[step.ID]; ok {
+			diff.RemovedSteps = append(diff.RemovedSteps, step)
+		}
+	}
+
+	oldLinks := make(map[uuid.UUID]*rDomain.IngredientLinkSnapshot, len(from.Ingredients))
+	for _, link := range from.Ingredients {
+		oldLinks[link.IngredientId] = link
+	}
+	for _, link := range to.Ingredients {
+		old, ok := oldLinks[link.IngredientId]
+		if !ok {
+			diff.AddedIngredients = append(diff.AddedIngredients, link)
+			continue
+		}
+		delete(oldLinks, link.IngredientId)
+		if old.Measurement != link.Measurement || old.Amount != link.Amount {
+			diff.ChangedIngredients = append(diff.ChangedIngredients,
+				&rDomain.IngredientLinkChange{Old: old, New: link})
+		}
+	}
+	for _, link := range from.Ingredients {
+		if _, ok := oldLinks[link.IngredientId]; ok {
+			diff.RemovedIngredients = append(diff.RemovedIngredients, link)
+		}
+	}
+
+	for _, typeId := range to.Types {
+		if !slices.Contains(from.Types, typeId) {
+			diff.AddedTypes = append(diff.AddedTypes, typeId)
+		}
+	}
+	for _, typeId := range from.Types {
+		if !slices.Contains(to.Types, typeId) {
+			diff.RemovedTypes = append(diff.RemovedTypes, typeId)
+		}
+	}
+	return diff
+}
+
+func stepChanged(old *rDomain.StepSnapshot, new *rDomain.StepSnapshot) bool {
+	return old.Name != new.Name ||
+		old.Description != new.Description ||
+		old.StepNum != new.StepNum ||
+		old.Section != new.Section ||
+		old.Duration != new.Duration ||
+		old.TimerLabel != new.TimerLabel ||
+		!slices.Equal(old.LinkIds, new.LinkIds)
+}
@@ -0,0 +1,209 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) rDomain.IReportRepository {
+	return &reportRepository{
+		db: db,
+	}
+}
+
+var reportTargetTables = map[string]string{
+	rDomain.ReportTargetSalad:      "salad",
+	rDomain.ReportTargetRecipeStep: "recipeStep",
+	rDomain.ReportTargetComment:    "comment",
+}
+
+func (r *reportRepository) Create(ctx context.Context, report *rDomain.Report) (uuid.UUID, error) {
+	table, ok := reportTargetTables[report.TargetKind]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("creating report: %w", rDomain.ErrUnknownReportTarget)
+	}
+	if report.Reason < rDomain.ReportReasonSpam || report.Reason > rDomain.ReportReasonOther {
+		return uuid.Nil, fmt.Errorf("creating report: %w", rDomain.ErrUnknownReportReason)
+	}
+
+	dbReport := *report
+	dbReport.ID = idOrNew(report.ID)
+	dbReport.CreatedAt = time.Now()
+	dbReport.Status = rDomain.ReportStatusOpen
+	dbReport.Action = nil
+	dbReport.ModeratorID = uuid.NullUUID{}
+	dbReport.ResolvedAt = nil
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Table(table).
+			Where("id = ?", dbReport.TargetID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return createWithId(tx, &dbReport, dbReport.ID)
+	})
+	if isDuplicateKey(err) {
+		return uuid.Nil, fmt.Errorf("creating report: %w", rDomain.ErrDuplicateReport)
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating report: %w", err)
+	}
+	return dbReport.ID, nil
+}
+
+func (r *reportRepository) GetById(ctx context.Context, id uuid.UUID) (*rDomain.Report, error) {
+	var report rDomain.Report
+	err := r.db.WithContext(ctx).
+		First(&report, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting report by id: %w", err)
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) GetByTarget(ctx context.Context, targetKind string, targetId uuid.UUID) ([]*rDomain.Report, error) {
+	var reports []*rDomain.Report
+	err := r.db.WithContext(ctx).
+		Where("targetKind = ?", targetKind).
+		Where("targetId = ?", targetId).
+		Order("createdAt desc").
+		Find(&reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting reports by target: %w", err)
+	}
+	return reports, nil
+}
+
+func (r *reportRepository) GetSummary(ctx context.Context, targetKind string, targetId uuid.UUID) (*rDomain.ReportSummary, error) {
+	summaries, err := r.openSummaries(ctx, func(query *gorm.DB) *gorm.DB {
+		return query.
+			Where("targetKind = ?", targetKind).
+			Where("targetId = ?", targetId)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting report summary: %w", err)
+	}
+	if len(summaries) == 0 {
+		return nil, fmt.Errorf("getting report summary: %w", gorm.ErrRecordNotFound)
+	}
+	return summaries[0], nil
+}
+
+func (r *reportRepository) GetOpen(ctx context.Context, page int) ([]*rDomain.ReportSummary, int, error) {
+	summaries, err := r.openSummaries(ctx, func(query *gorm.DB) *gorm.DB {
+		return query.
+			Limit(PageSize).
+			Offset(PageSize * (page - 1))
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting open reports: %w", err)
+	}
+
+	var count int64
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Report{}).
+		Select("count(distinct targetKind, targetId)").
+		Where("status = ?", rDomain.ReportStatusOpen).
+		Scan(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting open reports: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return summaries, int(numPages), nil
+}
+
+// openSummaries groups open reports by target, most reported first, and
+// fills in the number of reports given for every reason
+func (r *reportRepository) openSummaries(ctx context.Context,
+	scope func(query *gorm.DB) *gorm.DB) ([]*rDomain.ReportSummary, error) {
+	summaries := make([]*rDomain.ReportSummary, 0)
+	err := r.db.WithContext(ctx).
+		Scopes(scope).
+		Model(&rDomain.Report{}).
+		Select(`targetKind, targetId, count(*) as count,
+			min(createdAt) as firstReportAt, max(createdAt) as lastReportAt`).
+		Where("status = ?", rDomain.ReportStatusOpen).
+		Group("targetKind").
+		Group("targetId").
+		Order("count desc").
+		Order("firstReportAt").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return summaries, nil
+	}
+
+	targetIds := make([]uuid.UUID, 0, len(summaries))
+	byTarget := make(map[string]*rDomain.ReportSummary, len(summaries))
+	for _, summary := range summaries {
+		summary.Reasons = make(map[int]int)
+		targetIds = append(targetIds, summary.TargetID)
+		byTarget[summary.TargetKind+summary.TargetID.String()] = summary
+	}
+
+	var reasons []struct {
+		TargetKind string    `gorm:"column:targetKind"`
+		TargetID   uuid.UUID `gorm:"column:targetId"`
+		Reason     int       `gorm:"column:reason"`
+		Count      int       `gorm:"column:count"`
+	}
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Report{}).
+		Select("targetKind, targetId, reason, count(*) as count").
+		Where("status = ?", rDomain.ReportStatusOpen).
+		Where("targetId in ?", targetIds).
+		Group("targetKind").
+		Group("targetId").
+		Group("reason").
+		Scan(&reasons).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, reason := range reasons {
+		if summary, ok := byTarget[reason.TargetKind+reason.TargetID.String()]; ok {
+			summary.Reasons[reason.Reason] = reason.Count
+		}
+	}
+	return summaries, nil
+}
+
+func (r *reportRepository) Resolve(ctx context.Context, targetKind string, targetId uuid.UUID,
+	action int, moderatorId uuid.UUID) (int, error) {
+	if action < rDomain.ReportActionDismiss || action > rDomain.ReportActionWarnAuthor {
+		return 0, fmt.Errorf("resolving reports: %w", rDomain.ErrUnknownReportAction)
+	}
+	res := r.db.WithContext(ctx).
+		Model(&rDomain.Report{}).
+		Where("targetKind = ?", targetKind).
+		Where("targetId = ?", targetId).
+		Where("status = ?", rDomain.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      rDomain.ReportStatusResolved,
+			"action":      action,
+			"moderatorId": moderatorId,
+			"resolvedAt":  time.Now(),
+		})
+	if res.Error != nil {
+		return 0, fmt.Errorf("resolving reports: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
@@ -0,0 +1,212 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) rDomain.IRoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) GetAll(ctx context.Context) ([]*rDomain.Role, error) {
+	var roles []*rDomain.Role
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting all roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetRolePermissions(ctx context.Context, roleName string) ([]*rDomain.Permission, error) {
+	var permissions []*rDomain.Permission
+	err := r.db.WithContext(ctx).
+		Joins("join saladRecipes.rolePermission on rolePermission.permissionId = permission.id").
+		Joins("join saladRecipes.role on role.id = rolePermission.roleId").
+		Where("role.name = ?", roleName).
+		Order("permission.name").
+		Find(&permissions).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting role permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+// Assign gives the user every listed role, roles the user already has are
+// left as they are
+func (r *roleRepository) Assign(ctx context.Context, userId uuid.UUID, roleNames ...string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := assignRoles(tx, userId, roleNames...)
+		if err != nil {
+			return err
+		}
+		return syncPrimaryRole(tx, userId)
+	})
+	if err != nil {
+		return fmt.Errorf("assigning roles: %w", err)
+	}
+	return nil
+}
+
+func assignRoles(tx *gorm.DB, userId uuid.UUID, roleNames ...string) error {
+	if len(roleNames) == 0 {
+		return nil
+	}
+	var roles []*rDomain.Role
+	err := tx.Where("name in ?", roleNames).
+		Find(&roles).Error
+	if err != nil {
+		return err
+	}
+	if len(roles) != len(uniqueStrings(roleNames)) {
+		return rDomain.ErrUnknownRole
+	}
+
+	userRoles := make([]*rDomain.UserRole, 0, len(roles))
+	for _, role := range roles {
+		userRoles = append(userRoles, &rDomain.UserRole{UserID: userId, RoleID: role.ID})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&userRoles).Error
+}
+
+func (r *roleRepository) Revoke(ctx context.Context, userId uuid.UUID, roleName string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("userId = ?", userId).
+			Where("roleId in (?)", tx.Model(&rDomain.Role{}).Select("id").Where("name = ?", roleName)).
+			Delete(&rDomain.UserRole{}).Error
+		if err != nil {
+			return err
+		}
+		return syncPrimaryRole(tx, userId)
+	})
+	if err != nil {
+		return fmt.Errorf("revoking role: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) GetUserRoles(ctx context.Context, userId uuid.UUID) ([]*rDomain.Role, error) {
+	var roles []*rDomain.Role
+	err := r.db.WithContext(ctx).
+		Joins("join saladRecipes.userRole on userRole.roleId = role.id").
+		Where("userRole.userId = ?", userId).
+		Order("role.name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting user roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]string, error) {
+	permissions := make([]string, 0)
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.Permission{}).
+		Distinct("permission.name").
+		Joins("join saladRecipes.rolePermission on rolePermission.permissionId = permission.id").
+		Joins("join saladRecipes.userRole on userRole.roleId = rolePermission.roleId").
+		Where("userRole.userId = ?", userId).
+		Order("permission.name").
+		Pluck("permission.name", &permissions).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting user permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *roleRepository) HasPermission(ctx context.Context, userId uuid.UUID, permission string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.Permission{}).
+		Joins("join saladRecipes.rolePermission on rolePermission.permissionId = permission.id").
+		Joins("join saladRecipes.userRole on userRole.roleId = rolePermission.roleId").
+		Where("userRole.userId = ?", userId).
+		Where("permission.name = ?", permission).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("checking user permission: %w", err)
+	}
+	return count > 0, nil
+}
+
+// rolePriority orders roles for the user.role column, which keeps the most
+// privileged role of the user for code that still reads a single role
+var rolePriority = map[string]int{
+	rDomain.RoleUser:      1,
+	rDomain.RoleModerator: 2,
+	rDomain.RoleAdmin:     3,
+}
+
+// syncPrimaryRole writes the most privileged role the user has to user.role,
+// or the default role once the last role is revoked
+func syncPrimaryRole(tx *gorm.DB, userId uuid.UUID) error {
+	var names []string
+	err := tx.Model(&rDomain.Role{}).
+		Joins("join saladRecipes.userRole on userRole.roleId = role.id").
+		Where("userRole.userId = ?", userId).
+		Order("role.name").
+		Pluck("role.name", &names).Error
+	if err != nil {
+		return err
+	}
+
+	primary := ""
+	for _, name := range names {
+		if primary == "" || rolePriority[name] > rolePriority[primary] {
+			primary = name
+		}
+	}
+	if primary == "" {
+		primary = rDomain.DefaultRole
+	}
+	return tx.Model(&rDomain.User{}).
+		Where("id = ?", userId).
+		Update("role", primary).Error
+}
+
+// setUserRole makes roleName the only role of the user for the write paths
+// that take a single role. A role missing from the role table is rejected
+func setUserRole(tx *gorm.DB, userId uuid.UUID, roleName string) error {
+	var count int64
+	err := tx.Model(&rDomain.Role{}).
+		Where("name = ?", roleName).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return rDomain.ErrUnknownRole
+	}
+
+	err = tx.Where("userId = ?", userId).
+		Delete(&rDomain.UserRole{}).Error
+	if err != nil {
+		return err
+	}
+	return assignRoles(tx, userId, roleName)
+}
+
+func uniqueStrings(strs []string) []string {
+	seen := make(map[string]struct{}, len(strs))
+	res := make([]string, 0, len(strs))
+	for _, str := range strs {
+		if _, ok := seen[str]; !ok {
+			seen[str] = struct{}{}
+			res = append(res, str)
+		}
+	}
+	return res
+}
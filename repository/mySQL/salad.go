@@ -7,6 +7,7 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"slices"
 )
 
@@ -22,9 +23,8 @@ func NewSaladRepository(db *gorm.DB) rDomain.ISaladRepository {
 
 func (r *saladRepository) Create(ctx context.Context, salad *domain.Salad) (uuid.UUID, error) {
 	dbSalad := rDomain.ToSaladDB(salad)
-	dbSalad.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&dbSalad).Error
+	dbSalad.ID = idOrNew(salad.ID)
+	err := createWithId(r.db.WithContext(ctx), &dbSalad, dbSalad.ID)
 
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("creating salad: %w", err)
@@ -230,8 +230,15 @@ func (r *saladRepository) GetAllRatedByUser(ctx context.Context, userId uuid.UUI
 
 func (r *saladRepository) Update(ctx context.Context, salad *domain.Salad) error {
 	dbSalad := rDomain.ToSaladDB(salad)
-	err := r.db.WithContext(ctx).
-		Save(&dbSalad).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdBySalad(tx, dbSalad.ID)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Save(&dbSalad).Error
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("updating salad: %w", err)
 	}
@@ -246,3 +253,249 @@ func (r *saladRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+func (r *saladRepository) Clone(ctx context.Context, saladId uuid.UUID, newAuthorId uuid.UUID) (uuid.UUID, error) {
+	clone := rDomain.Salad{
+		ID:       uuid.New(),
+		AuthorID: newAuthorId,
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var source rDomain.Salad
+		err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+			First(&source, saladId).Error
+		if err != nil {
+			return fmt.Errorf("getting source salad: %w", err)
+		}
+
+		clone.Name = source.Name
+		clone.Description = source.Description
+		err = tx.Create(&clone).Error
+		if err != nil {
+			return fmt.Errorf("creating salad: %w", err)
+		}
+		err = tx.Model(&rDomain.Salad{}).
+			Where("id = ?", clone.ID).
+			Update("parentId", source.ID).Error
+		if err != nil {
+			return fmt.Errorf("setting parent salad: %w", err)
+		}
+
+		var types []*rDomain.TypeLink
+		err = tx.Where("saladId = ?", source.ID).
+			Find(&types).Error
+		if err != nil {
+			return fmt.Errorf("getting salad types: %w", err)
+		}
+		for _, link := range types {
+			link.ID = uuid.New()
+			link.SaladId = clone.ID
+		}
+		if len(types) != 0 {
+			err = tx.Create(&types).Error
+			if err != nil {
+				return fmt.Errorf("copying salad types: %w", err)
+			}
+		}
+
+		var recipes []*rDomain.Recipe
+		err = tx.Where("saladId = ?", source.ID).
+			Limit(1).
+			Find(&recipes).Error
+		if err != nil {
+			return fmt.Errorf("getting source recipe: %w", err)
+		}
+		if len(recipes) == 0 {
+			return nil
+		}
+		return cloneRecipe(tx, recipes[0], clone.ID)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("cloning salad: %w", err)
+	}
+	return clone.ID, nil
+}
+
+func cloneRecipe(tx *gorm.DB, source *rDomain.Recipe, saladId uuid.UUID) error {
+	recipe := rDomain.Recipe{
+		ID:               uuid.New(),
+		SaladID:          saladId,
+		Status:           domain.EditingSaladStatus,
+		NumberOfServings: source.NumberOfServings,
+		TimeToCook:       source.TimeToCook,
+	}
+	err := tx.Create(&recipe).Error
+	if err != nil {
+		return fmt.Errorf("creating recipe: %w", err)
+	}
+
+	details, err := getRecipeDetails(tx, source.ID)
+	if err != nil {
+		return fmt.Errorf("getting recipe details: %w", err)
+	}
+	err = tx.Model(&rDomain.RecipeDetails{}).
+		Where("id = ?", recipe.ID).
+		Updates(map[string]interface{}{
+			"prepMinutes": details.PrepMinutes,
+			"cookMinutes": details.CookMinutes,
+			"restMinutes": details.RestMinutes,
+			"difficulty":  details.Difficulty,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("copying recipe details: %w", err)
+	}
+	equipment := make([]uuid.UUID, 0, len(details.Equipment))
+	for _, item := range details.Equipment {
+		equipment = append(equipment, item.ID)
+	}
+	err = setRecipeEquipment(tx, recipe.ID, equipment)
+	if err != nil {
+		return fmt.Errorf("copying recipe equipment: %w", err)
+	}
+
+	var steps []*rDomain.RecipeStep
+	err = tx.Where("recipeId = ?", source.ID).
+		Order("stepNum").
+		Find(&steps).Error
+	if err != nil {
+		return fmt.Errorf("getting recipe steps: %w", err)
+	}
+	var stepLinks []*rDomain.StepIngredientLink
+	err = tx.Joins("join recipeStep on recipeStep.id = stepIngredient.stepId").
+		Where("recipeStep.recipeId = ?", source.ID).
+		Find(&stepLinks).Error
+	if err != nil {
+		return fmt.Errorf("getting step ingredients: %w", err)
+	}
+	newIds := make(map[uuid.UUID]uuid.UUID)
+	for _, step := range steps {
+		newIds[step.ID] = uuid.New()
+		step.ID = newIds[step.ID]
+		step.RecipeID = recipe.ID
+	}
+	if len(steps) != 0 {
+		err = tx.Create(&steps).Error
+		if err != nil {
+			return fmt.Errorf("copying recipe steps: %w", err)
+		}
+	}
+
+	var links []*rDomain.IngredientLink
+	err = tx.Where("recipeId = ?", source.ID).
+		Find(&links).Error
+	if err != nil {
+		return fmt.Errorf("getting recipe ingredients: %w", err)
+	}
+	for _, link := range links {
+		newIds[link.ID] = uuid.New()
+		link.ID = newIds[link.ID]
+		link.RecipeId = recipe.ID
+	}
+	if len(links) != 0 {
+		err = tx.Create(&links).Error
+		if err != nil {
+			return fmt.Errorf("copying recipe ingredients: %w", err)
+		}
+	}
+
+	for _, link := range stepLinks {
+		link.ID = uuid.New()
+		link.StepId = newIds[link.StepId]
+		link.LinkId = newIds[link.LinkId]
+	}
+	if len(stepLinks) != 0 {
+		err = tx.Create(&stepLinks).Error
+		if err != nil {
+			return fmt.Errorf("copying step ingredients: %w", err)
+		}
+	}
+
+	err = recordRecipeVersion(tx, recipe.ID)
+	if err != nil {
+		return fmt.Errorf("recording recipe version: %w", err)
+	}
+	return nil
+}
+
+func (r *saladRepository) GetParent(ctx context.Context, saladId uuid.UUID) (*domain.Salad, error) {
+	var salad rDomain.Salad
+	err := r.db.WithContext(ctx).
+		Where("id = (?)", r.db.
+			Model(&rDomain.Salad{}).
+			Select("parentId").
+			Where("id = ?", saladId)).
+		First(&salad).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting parent salad: %w", err)
+	}
+	return rDomain.ToSaladBL(&salad), nil
+}
+
+func (r *saladRepository) GetForks(ctx context.Context, saladId uuid.UUID, page int) ([]*domain.Salad, int, error) {
+	var dbSalads []*rDomain.Salad
+	err := r.db.WithContext(ctx).
+		Where("parentId = ?", saladId).
+		Order("name").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Find(&dbSalads).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting salad forks: %w", err)
+	}
+
+	salads := make([]*domain.Salad, 0)
+	for _, salad := range dbSalads {
+		salads = append(salads, rDomain.ToSaladBL(salad))
+	}
+
+	var count int64
+	err = r.db.WithContext(ctx).
+		Model(&rDomain.Salad{}).
+		Where("parentId = ?", saladId).
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting salad forks: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return salads, int(numPages), nil
+}
+
+func (r *saladRepository) GetAllFiltered(ctx context.Context, filter *rDomain.RecipeListFilter, page int) ([]*domain.Salad, int, error) {
+	query := func() *gorm.DB {
+		return applyRecipeFilter(r.db.WithContext(ctx).
+			Table("salad").
+			Joins("join recipe on recipe.saladId = salad.id"), filter)
+	}
+
+	var dbSalads []*rDomain.Salad
+	err := query().
+		Select("salad.id", "salad.authorId", "salad.name", "salad.description").
+		Order("recipe.rating desc").
+		Limit(PageSize).
+		Offset(PageSize * (page - 1)).
+		Find(&dbSalads).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting filtered salads: %w", err)
+	}
+
+	salads := make([]*domain.Salad, 0)
+	for _, salad := range dbSalads {
+		salads = append(salads, rDomain.ToSaladBL(salad))
+	}
+
+	var count int64
+	err = query().
+		Count(&count).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting filtered salads: %w", err)
+	}
+	numPages := count / PageSize
+	if count%PageSize != 0 {
+		numPages++
+	}
+
+	return salads, int(numPages), nil
+}
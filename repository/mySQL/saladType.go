@@ -19,15 +19,14 @@ func NewSaladTypeRepository(db *gorm.DB) rDomain.ISaladTypeRepository {
 	}
 }
 
-func (r *saladTypeRepository) Create(ctx context.Context, saladType *domain.SaladType) error {
+func (r *saladTypeRepository) Create(ctx context.Context, saladType *domain.SaladType) (uuid.UUID, error) {
 	typeDb := rDomain.ToSaladTypeDB(saladType)
-	typeDb.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(&typeDb).Error
+	typeDb.ID = idOrNew(saladType.ID)
+	err := createWithId(r.db.WithContext(ctx), &typeDb, typeDb.ID)
 	if err != nil {
-		return fmt.Errorf("creating salad type: %w", err)
+		return uuid.Nil, fmt.Errorf("creating salad type: %w", err)
 	}
-	return nil
+	return typeDb.ID, nil
 }
 
 func (r *saladTypeRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.SaladType, error) {
@@ -105,22 +104,89 @@ func (r *saladTypeRepository) Update(ctx context.Context, saladType *domain.Sala
 }
 
 func (r *saladTypeRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Delete(&rDomain.SaladType{}, id).Error
+	return r.DeleteWithOptions(ctx, id, nil)
+}
+
+func (r *saladTypeRepository) DeleteWithOptions(ctx context.Context, id uuid.UUID, opts *rDomain.DeleteOptions) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteWithUsages(tx, &rDomain.SaladType{}, id, opts, saladTypeUsages, replaceSaladType)
+	})
 	if err != nil {
 		return fmt.Errorf("deleting salad type by id: %w", err)
 	}
 	return nil
 }
 
+func (r *saladTypeRepository) UsageCount(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.TypeLink{}).
+		Where("typeId = ?", id).
+		Distinct("saladId").
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting salad type usages: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *saladTypeRepository) GetUsages(ctx context.Context, id uuid.UUID) ([]*rDomain.Usage, error) {
+	usages, err := saladTypeUsages(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, fmt.Errorf("getting salad type usages: %w", err)
+	}
+	return usages, nil
+}
+
+func saladTypeUsages(db *gorm.DB, id uuid.UUID) ([]*rDomain.Usage, error) {
+	var usages []*rDomain.Usage
+	err := db.Table("typesOfSalads").
+		Distinct("? as kind, salad.id as id, salad.name as name", rDomain.UsageKindSalad).
+		Joins("join salad on salad.id = typesOfSalads.saladId").
+		Where("typesOfSalads.typeId = ?", id).
+		Order("salad.name").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func replaceSaladType(tx *gorm.DB, id uuid.UUID, replacementId uuid.UUID) error {
+	var alreadyLinked []uuid.UUID
+	err := tx.Model(&rDomain.TypeLink{}).
+		Where("typeId = ?", replacementId).
+		Pluck("saladId", &alreadyLinked).Error
+	if err != nil {
+		return err
+	}
+
+	if len(alreadyLinked) != 0 {
+		err = tx.Where("typeId = ?", id).
+			Where("saladId in ?", alreadyLinked).
+			Delete(&rDomain.TypeLink{}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&rDomain.TypeLink{}).
+		Where("typeId = ?", id).
+		Update("typeId", replacementId).Error
+}
+
 func (r *saladTypeRepository) Link(ctx context.Context, saladId uuid.UUID, saladTypeId uuid.UUID) error {
 	dbLink := rDomain.TypeLink{
 		ID:      uuid.New(), // TODO: mb should generate uuid there
 		SaladId: saladId,
 		TypeId:  saladTypeId,
 	}
-	err := r.db.WithContext(ctx).
-		Create(&dbLink).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdBySalad(tx, saladId)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Create(&dbLink).Error
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("linking types from salad: %w", err)
 	}
@@ -128,10 +194,17 @@ func (r *saladTypeRepository) Link(ctx context.Context, saladId uuid.UUID, salad
 }
 
 func (r *saladTypeRepository) Unlink(ctx context.Context, saladId uuid.UUID, saladTypeId uuid.UUID) error {
-	err := r.db.WithContext(ctx).
-		Where("saladId = ?", saladId).
-		Where("typeId = ?", saladTypeId).
-		Delete(&rDomain.TypeLink{}).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipeId, err := recipeIdBySalad(tx, saladId)
+		if err != nil {
+			return err
+		}
+		return withRecipeVersion(tx, recipeId, func(tx *gorm.DB) error {
+			return tx.Where("saladId = ?", saladId).
+				Where("typeId = ?", saladTypeId).
+				Delete(&rDomain.TypeLink{}).Error
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("unlinking types from salad: %w", err)
 	}
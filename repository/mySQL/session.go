@@ -0,0 +1,166 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) rDomain.ISessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *rDomain.Session, refreshToken string) (uuid.UUID, error) {
+	dbSession := *session
+	dbSession.ID = idOrNew(session.ID)
+	dbSession.FamilyID = dbSession.ID
+	dbSession.RefreshHash = hashToken(refreshToken)
+	dbSession.CreatedAt = time.Now()
+	dbSession.RevokedAt = nil
+	dbSession.ReplacedBy = uuid.NullUUID{}
+
+	err := createWithId(r.db.WithContext(ctx), &dbSession, dbSession.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating session: %w", err)
+	}
+	return dbSession.ID, nil
+}
+
+func (r *sessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*rDomain.Session, error) {
+	var session rDomain.Session
+	err := r.db.WithContext(ctx).
+		Where("refreshHash = ?", hashToken(refreshToken)).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("getting session by refresh token: %w", rDomain.ErrSessionNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting session by refresh token: %w", err)
+	}
+	return &session, nil
+}
+
+// Rotate replaces the session owning oldToken by a new one holding newToken.
+// Presenting a token that was already rotated means it leaked, so the whole
+// chain of sessions started by the same login is revoked
+func (r *sessionRepository) Rotate(ctx context.Context, oldToken string, newToken string,
+	expiresAt time.Time) (*rDomain.Session, error) {
+	var newSession *rDomain.Session
+	reused := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old rDomain.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refreshHash = ?", hashToken(oldToken)).
+			First(&old).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return rDomain.ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if old.ReplacedBy.Valid {
+			reused = true
+			return tx.Model(&rDomain.Session{}).
+				Where("familyId = ?", old.FamilyID).
+				Where("revokedAt is null").
+				Update("revokedAt", now).Error
+		}
+		if old.RevokedAt != nil {
+			return rDomain.ErrSessionRevoked
+		}
+		if !old.ExpiresAt.After(now) {
+			return rDomain.ErrSessionExpired
+		}
+
+		newSession = &rDomain.Session{
+			ID:          uuid.New(),
+			UserID:      old.UserID,
+			FamilyID:    old.FamilyID,
+			RefreshHash: hashToken(newToken),
+			Device:      old.Device,
+			UserAgent:   old.UserAgent,
+			CreatedAt:   now,
+			ExpiresAt:   expiresAt,
+		}
+		err = tx.Create(newSession).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&old).
+			Updates(map[string]interface{}{
+				"revokedAt":  now,
+				"replacedBy": newSession.ID,
+			}).Error
+	})
+	if err == nil && reused {
+		err = rDomain.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rotating refresh token: %w", err)
+	}
+	return newSession, nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, sessionId uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.Session{}).
+		Where("id = ?", sessionId).
+		Where("revokedAt is null").
+		Update("revokedAt", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAll(ctx context.Context, userId uuid.UUID) (int, error) {
+	res := r.db.WithContext(ctx).
+		Model(&rDomain.Session{}).
+		Where("userId = ?", userId).
+		Where("revokedAt is null").
+		Update("revokedAt", time.Now())
+	if res.Error != nil {
+		return 0, fmt.Errorf("revoking all sessions: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
+
+func (r *sessionRepository) GetActive(ctx context.Context, userId uuid.UUID) ([]*rDomain.Session, error) {
+	var sessions []*rDomain.Session
+	err := r.db.WithContext(ctx).
+		Where("userId = ?", userId).
+		Where("revokedAt is null").
+		Where("expiresAt > ?", time.Now()).
+		Order("createdAt desc").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting active sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// PurgeExpired deletes expired sessions. Revoked sessions are kept until they
+// expire, otherwise reuse of their tokens could no longer be detected
+func (r *sessionRepository) PurgeExpired(ctx context.Context) (int, error) {
+	res := r.db.WithContext(ctx).
+		Where("expiresAt <= ?", time.Now()).
+		Delete(&rDomain.Session{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("purging expired sessions: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
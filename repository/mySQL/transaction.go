@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	maxTxAttempts         = 5
+	txRetryDelay          = 10 * time.Millisecond
+	mysqlDeadlockCode     = 1213
+	mysqlDuplicateKeyCode = 1062
+)
+
+// transactionWithRetry runs fn in a transaction and repeats it when MySQL
+// picks the transaction as a deadlock victim
+func transactionWithRetry(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = db.WithContext(ctx).Transaction(fn)
+		if !isDeadlock(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(txRetryDelay * time.Duration(attempt)):
+		}
+	}
+	return err
+}
+
+func isDeadlock(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDeadlockCode
+}
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateKeyCode
+}
+
+// lockRecipe takes a row lock on the recipe, serialising changes of its
+// steps. A missing recipe is not an error here, foreign keys report it
+func lockRecipe(tx *gorm.DB, recipeId uuid.UUID) error {
+	var ids []uuid.UUID
+	return tx.Table("recipe").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", recipeId).
+		Pluck("id", &ids).Error
+}
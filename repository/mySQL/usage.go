@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"fmt"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+)
+
+type usagesFunc func(db *gorm.DB, id uuid.UUID) ([]*rDomain.Usage, error)
+type replaceFunc func(tx *gorm.DB, id uuid.UUID, replacementId uuid.UUID) error
+
+// deleteWithUsages locks the row, then either moves its references to
+// opts.ReplacementId or refuses with rDomain.ErrInUse while they exist.
+// Deleting a missing row is a no-op, a missing replacement is
+// gorm.ErrRecordNotFound
+func deleteWithUsages(tx *gorm.DB, model interface{}, id uuid.UUID, opts *rDomain.DeleteOptions,
+	usages usagesFunc, replace replaceFunc) error {
+	ids := []uuid.UUID{id}
+	replacing := opts != nil && opts.ReplacementId != uuid.Nil
+	if replacing {
+		if opts.ReplacementId == id {
+			return rDomain.ErrReplaceWithItself
+		}
+		ids = append(ids, opts.ReplacementId)
+	}
+
+	var locked []uuid.UUID
+	err := tx.Model(model).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id in ?", ids).
+		Pluck("id", &locked).Error
+	if err != nil {
+		return fmt.Errorf("locking rows: %w", err)
+	}
+	if !slices.Contains(locked, id) {
+		return nil
+	}
+	if replacing && !slices.Contains(locked, opts.ReplacementId) {
+		return gorm.ErrRecordNotFound
+	}
+
+	if replacing {
+		err := replace(tx, id, opts.ReplacementId)
+		if err != nil {
+			return fmt.Errorf("replacing references: %w", err)
+		}
+	} else {
+		found, err := usages(tx, id)
+		if err != nil {
+			return fmt.Errorf("getting usages: %w", err)
+		}
+		if len(found) != 0 {
+			return &rDomain.ErrInUse{ID: id, Usages: found}
+		}
+	}
+
+	return tx.Delete(model, id).Error
+}
@@ -7,27 +7,40 @@ import (
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository(db *gorm.DB) domain.IUserRepository {
+func NewUserRepository(db *gorm.DB) rDomain.IUserRepository {
 	return &userRepository{
 		db: db,
 	}
 }
 
-func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+func (r *userRepository) Create(ctx context.Context, user *domain.User) (uuid.UUID, error) {
 	dbModel := rDomain.ToUserDB(user)
-	dbModel.ID = uuid.New()
-	err := r.db.WithContext(ctx).
-		Create(dbModel).Error
+	dbModel.ID = idOrNew(user.ID)
+	if dbModel.Role == "" {
+		dbModel.Role = rDomain.DefaultRole
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := createWithId(tx, dbModel, dbModel.ID)
+		if err != nil {
+			return err
+		}
+		err = setPasswordHashInfo(tx, dbModel.ID, dbModel.Password)
+		if err != nil {
+			return err
+		}
+		return setUserRole(tx, dbModel.ID, dbModel.Role)
+	})
 	if err != nil {
-		return fmt.Errorf("creating user: %w", err)
+		return uuid.Nil, fmt.Errorf("creating user: %w", err)
 	}
-	return nil
+	return dbModel.ID, nil
 }
 
 func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.User, error) {
@@ -58,11 +71,48 @@ func (r *userRepository) GetAll(ctx context.Context, page int) ([]*domain.User,
 	return resUsers, nil
 }
 
+// Update saves the user. An empty or unchanged role keeps all roles of the
+// user, a different role replaces them, the same way Register assigns it. A
+// changed email has to be verified again
 func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	dbUser := rDomain.ToUserDB(user)
-	err := r.db.WithContext(ctx).
-		Save(dbUser).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored []*rDomain.User
+		err := tx.Select("id", "email", "role").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", dbUser.ID).
+			Find(&stored).Error
+		if err != nil {
+			return err
+		}
+		if dbUser.Role == "" {
+			dbUser.Role = rDomain.DefaultRole
+			if len(stored) != 0 {
+				dbUser.Role = stored[0].Role
+			}
+		}
 
+		err = tx.Save(dbUser).Error
+		if err != nil {
+			return err
+		}
+		err = setPasswordHashInfo(tx, dbUser.ID, dbUser.Password)
+		if err != nil {
+			return err
+		}
+		if len(stored) != 0 && stored[0].Email != dbUser.Email {
+			err = tx.Model(&rDomain.User{}).
+				Where("id = ?", dbUser.ID).
+				Update("emailVerified", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(stored) != 0 && stored[0].Role == dbUser.Role {
+			return nil
+		}
+		return setUserRole(tx, dbUser.ID, dbUser.Role)
+	})
 	if err != nil {
 		return fmt.Errorf("updating user: %w", err)
 	}
@@ -87,3 +137,28 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*d
 	}
 	return rDomain.ToUserBL(dbUser), nil
 }
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var dbUser *rDomain.User
+	err := r.db.WithContext(ctx).
+		Where("email = ?", email).First(&dbUser).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting user by email: %w", err)
+	}
+	return rDomain.ToUserBL(dbUser), nil
+}
+
+func (r *userRepository) IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error) {
+	var verified []bool
+	err := r.db.WithContext(ctx).
+		Model(&rDomain.User{}).
+		Where("id = ?", id).
+		Pluck("emailVerified", &verified).Error
+	if err != nil {
+		return false, fmt.Errorf("checking email verification: %w", err)
+	}
+	if len(verified) == 0 {
+		return false, fmt.Errorf("checking email verification: %w", gorm.ErrRecordNotFound)
+	}
+	return verified[0], nil
+}
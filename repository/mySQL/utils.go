@@ -1,14 +1,55 @@
 package mysql
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"unicode"
 )
 
+// maxCacheLoadAttempts bounds how many times a lazily loaded cache is read
+// again because a change raced with the read
+const maxCacheLoadAttempts = 3
+
 func uuidToString(uuid uuid.UUID) string {
 	str := "uuid('" + uuid.String() + "')"
 	return str
 }
 
+// idOrNew keeps an ID chosen by the caller, so that a retried Create does not
+// produce a second row, and generates one otherwise
+func idOrNew(id uuid.UUID) uuid.UUID {
+	if id == uuid.Nil {
+		return uuid.New()
+	}
+	return id
+}
+
+// createWithId inserts value whose primary key is id. A duplicate of that key
+// means a retried Create, it is reported as rDomain.ErrAlreadyExists while
+// duplicates of other unique keys are returned unchanged
+func createWithId(tx *gorm.DB, value interface{}, id uuid.UUID) error {
+	err := tx.Create(value).Error
+	if !isDuplicateKey(err) {
+		return err
+	}
+
+	var count int64
+	countErr := tx.Model(value).
+		Where("id = ?", id).
+		Count(&count).Error
+	if countErr != nil {
+		return countErr
+	}
+	if count != 0 {
+		return rDomain.ErrAlreadyExists
+	}
+	return err
+}
+
 func uuidsToString(uuids []uuid.UUID) string {
 	if uuids == nil || len(uuids) == 0 {
 		return ""
@@ -21,3 +62,27 @@ func uuidsToString(uuids []uuid.UUID) string {
 	}
 	return str
 }
+
+func normalizeText(str string) string {
+	str = strings.TrimSpace(strings.ToLower(str))
+	return strings.Map(func(r rune) rune {
+		if r == 'ё' {
+			return 'е'
+		}
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, str)
+}
+
+func escapeLike(str string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(str)
+}
+
+// hashToken hashes high-entropy secrets such as refresh tokens, a plain
+// SHA-256 is enough for them and keeps lookups by hash possible
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
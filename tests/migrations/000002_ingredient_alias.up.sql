@@ -0,0 +1,10 @@
+create table if not exists saladRecipes.ingredientAlias (
+        id varchar(36) default (uuid()) primary key,
+        ingredientId varchar(36) not null,
+        alias varchar(32) not null unique,
+        foreign key (ingredientId) references saladRecipes.ingredient(id) on delete cascade
+    );
+
+insert into saladRecipes.ingredientAlias(ingredientId, alias)
+values ('01000000-0000-0000-0000-000000000000', 'морковка'),
+       ('03000000-0000-0000-0000-000000000000', 'сёмга');
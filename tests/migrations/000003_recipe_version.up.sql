@@ -0,0 +1,9 @@
+create table if not exists saladRecipes.recipeVersion (
+        id varchar(36) default (uuid()) primary key,
+        recipeId varchar(36) not null,
+        version int not null, check ( version > 0 ),
+        createdAt datetime not null default current_timestamp,
+        snapshot json not null,
+        unique (recipeId, version),
+        foreign key (recipeId) references saladRecipes.recipe(id) on delete cascade
+    );
@@ -0,0 +1,3 @@
+alter table saladRecipes.salad
+    add column parentId varchar(36) null,
+    add foreign key (parentId) references saladRecipes.salad(id) on delete set null;
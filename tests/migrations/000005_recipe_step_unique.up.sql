@@ -0,0 +1,2 @@
+alter table saladRecipes.recipeStep
+    add constraint recipeStepNum unique (recipeId, stepNum);
@@ -0,0 +1,13 @@
+alter table saladRecipes.recipeStep
+    add column section varchar(32) not null default '',
+    add column duration int not null default 0, add check ( duration >= 0 ),
+    add column timerLabel varchar(32) not null default '';
+
+create table if not exists saladRecipes.stepIngredient (
+        id varchar(36) default (uuid()) primary key,
+        stepId varchar(36) not null,
+        linkId varchar(36) not null,
+        unique (stepId, linkId),
+        foreign key (stepId) references saladRecipes.recipeStep(id) on delete cascade,
+        foreign key (linkId) references saladRecipes.recipeIngredient(id) on delete cascade
+    );
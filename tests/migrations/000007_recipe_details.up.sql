@@ -0,0 +1,26 @@
+alter table saladRecipes.recipe
+    add column prepMinutes int not null default 0, add check ( prepMinutes >= 0 ),
+    add column cookMinutes int not null default 0, add check ( cookMinutes >= 0 ),
+    add column restMinutes int not null default 0, add check ( restMinutes >= 0 ),
+    add column difficulty int not null default 0, add check ( difficulty between 0 and 3 );
+
+create table if not exists saladRecipes.equipment (
+        id varchar(36) default (uuid()) primary key,
+        name varchar(32) not null unique
+    );
+
+create table if not exists saladRecipes.recipeEquipment (
+        id varchar(36) default (uuid()) primary key,
+        recipeId varchar(36) not null,
+        equipmentId varchar(36) not null,
+        unique (recipeId, equipmentId),
+        foreign key (recipeId) references saladRecipes.recipe(id) on delete cascade,
+        foreign key (equipmentId) references saladRecipes.equipment(id)
+    );
+
+insert into saladRecipes.equipment(id, name)
+values ('01000000-0000-0000-0000-000000000000', 'духовка'),
+       ('02000000-0000-0000-0000-000000000000', 'плита'),
+       ('03000000-0000-0000-0000-000000000000', 'блендер'),
+       ('04000000-0000-0000-0000-000000000000', 'миксер'),
+       ('05000000-0000-0000-0000-000000000000', 'гриль');
@@ -0,0 +1,12 @@
+create table if not exists saladRecipes.commentReply (
+        id varchar(36) default (uuid()) primary key,
+        commentId varchar(36) not null,
+        parentId varchar(36) null,
+        author varchar(36) not null,
+        text varchar(256) not null, check ( text <> '' ),
+        depth int not null, check ( depth > 0 ),
+        createdAt datetime not null default current_timestamp,
+        foreign key (commentId) references saladRecipes.comment(id) on delete cascade,
+        foreign key (parentId) references saladRecipes.commentReply(id) on delete cascade,
+        foreign key (author) references saladRecipes.user(id)
+    );
@@ -0,0 +1,12 @@
+alter table saladRecipes.comment
+    add column createdAt datetime not null default current_timestamp;
+
+create table if not exists saladRecipes.commentVote (
+        id varchar(36) default (uuid()) primary key,
+        commentId varchar(36) not null,
+        userId varchar(36) not null,
+        helpful bool not null,
+        unique (commentId, userId),
+        foreign key (commentId) references saladRecipes.comment(id) on delete cascade,
+        foreign key (userId) references saladRecipes.user(id) on delete cascade
+    );
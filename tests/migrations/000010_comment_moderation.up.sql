@@ -0,0 +1,13 @@
+alter table saladRecipes.comment
+    add column moderation int not null default 0, add check ( moderation between 0 and 2 ),
+    add column moderationReason varchar(256) not null default '',
+    add column moderatedAt datetime null;
+
+create table if not exists saladRecipes.commentRevision (
+        id varchar(36) default (uuid()) primary key,
+        commentId varchar(36) not null,
+        text varchar(64) default '',
+        rating int not null,
+        editedAt datetime not null default current_timestamp,
+        foreign key (commentId) references saladRecipes.comment(id) on delete cascade
+    );
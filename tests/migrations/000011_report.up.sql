@@ -0,0 +1,21 @@
+create table if not exists saladRecipes.report (
+        id varchar(36) default (uuid()) primary key,
+        reporterId varchar(36) not null,
+        targetKind varchar(16) not null,
+        targetId varchar(36) not null,
+        reason int not null,
+        details varchar(256) not null default '',
+        createdAt datetime not null default current_timestamp,
+        status int not null default 0,
+        action int null,
+        moderatorId varchar(36) null,
+        resolvedAt datetime null,
+        openMarker bool as (if(status = 0, true, null)) stored,
+        check ( targetKind in ('salad', 'recipeStep', 'comment') ),
+        check ( reason between 1 and 5 ),
+        check ( status between 0 and 1 ),
+        unique (reporterId, targetKind, targetId, openMarker),
+        index (targetKind, targetId, status),
+        foreign key (reporterId) references saladRecipes.user(id) on delete cascade,
+        foreign key (moderatorId) references saladRecipes.user(id) on delete set null
+    );
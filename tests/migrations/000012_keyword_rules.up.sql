@@ -0,0 +1,8 @@
+alter table saladRecipes.word
+    modify column word varchar(128) not null,
+    add column category varchar(16) not null default 'profanity',
+    add column severity int not null default 3,
+    add column kind int not null default 0,
+    add check ( category in ('profanity', 'spam', 'advertising') ),
+    add check ( severity between 1 and 3 ),
+    add check ( kind between 0 and 2 );
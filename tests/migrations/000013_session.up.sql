@@ -0,0 +1,15 @@
+create table if not exists saladRecipes.session (
+        id varchar(36) default (uuid()) primary key,
+        userId varchar(36) not null,
+        familyId varchar(36) not null,
+        refreshHash char(64) not null unique,
+        device varchar(128) not null default '',
+        userAgent varchar(512) not null default '',
+        createdAt datetime not null default current_timestamp,
+        expiresAt datetime not null,
+        revokedAt datetime null,
+        replacedBy varchar(36) null,
+        index (userId, expiresAt),
+        index (familyId),
+        foreign key (userId) references saladRecipes.user(id) on delete cascade
+    );
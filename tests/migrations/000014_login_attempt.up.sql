@@ -0,0 +1,15 @@
+alter table saladRecipes.user
+    add column lockedUntil datetime null;
+
+create table if not exists saladRecipes.loginAttempt (
+        id varchar(36) default (uuid()) primary key,
+        userId varchar(36) null,
+        login varchar(64) not null,
+        ip varchar(45) not null,
+        success bool not null,
+        createdAt datetime not null default current_timestamp,
+        index (userId, success, createdAt),
+        index (ip, success, createdAt),
+        index (createdAt),
+        foreign key (userId) references saladRecipes.user(id) on delete cascade
+    );
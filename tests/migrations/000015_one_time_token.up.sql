@@ -0,0 +1,16 @@
+alter table saladRecipes.user
+    add column emailVerified bool not null default false;
+
+create table if not exists saladRecipes.oneTimeToken (
+        id varchar(36) default (uuid()) primary key,
+        userId varchar(36) not null,
+        purpose varchar(32) not null,
+        tokenHash char(64) not null unique,
+        createdAt datetime not null default current_timestamp,
+        expiresAt datetime not null,
+        consumedAt datetime null,
+        check ( purpose in ('emailVerification', 'passwordReset') ),
+        index (userId, purpose),
+        index (expiresAt),
+        foreign key (userId) references saladRecipes.user(id) on delete cascade
+    );
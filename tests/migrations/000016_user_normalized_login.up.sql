@@ -0,0 +1,41 @@
+# Accounts whose login or email becomes equal to another one after lower() and
+# trim() collide. The table has no creation time, so in every group the account
+# whose stored value is already normalised keeps the normalised value, and if
+# there is none the one with the most salads does; ids only break the remaining
+# ties. The other accounts are marked with the account they collide with and
+# left out of the unique normalised columns, but can still sign in with the
+# exact login or email they registered with. The userCollision view reports them
+alter table saladRecipes.user
+    add column loginCollisionOf varchar(36) null,
+    add column emailCollisionOf varchar(36) null;
+
+update saladRecipes.user u
+    join (select id, first_value(id) over (
+                     partition by lower(trim(login))
+                     order by cast(login as binary) = cast(lower(trim(login)) as binary) desc,
+                         (select count(*) from saladRecipes.salad where salad.authorId = user.id) desc,
+                         id) as keepId
+          from saladRecipes.user) d on u.id = d.id and u.id <> d.keepId
+set u.loginCollisionOf = d.keepId;
+
+update saladRecipes.user u
+    join (select id, first_value(id) over (
+                     partition by lower(trim(email))
+                     order by cast(email as binary) = cast(lower(trim(email)) as binary) desc,
+                         (select count(*) from saladRecipes.salad where salad.authorId = user.id) desc,
+                         id) as keepId
+          from saladRecipes.user) d on u.id = d.id and u.id <> d.keepId
+set u.emailCollisionOf = d.keepId;
+
+alter table saladRecipes.user
+    add column loginNormalized varchar(64) collate utf8mb4_bin
+        as (if(loginCollisionOf is null, lower(trim(login)), null)) stored,
+    add column emailNormalized varchar(64) collate utf8mb4_bin
+        as (if(emailCollisionOf is null, lower(trim(email)), null)) stored,
+    add unique index userLoginNormalized (loginNormalized),
+    add unique index userEmailNormalized (emailNormalized);
+
+create or replace view saladRecipes.userCollision as
+    select id, login, email, loginCollisionOf, emailCollisionOf
+    from saladRecipes.user
+    where loginCollisionOf is not null or emailCollisionOf is not null;
@@ -0,0 +1,14 @@
+alter table saladRecipes.user
+    add column passwordAlgorithm varchar(16) not null default 'bcrypt',
+    add column passwordParams json null;
+
+update saladRecipes.user
+set passwordAlgorithm = case
+        when password like '$2_$%' then 'bcrypt'
+        when password like '$argon2id$%' then 'argon2id'
+        else 'unknown'
+    end,
+    passwordParams = case
+        when password like '$2_$%' then json_object('cost', cast(substring(password, 5, 2) as unsigned))
+        else json_object()
+    end;
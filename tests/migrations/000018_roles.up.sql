@@ -0,0 +1,55 @@
+create table if not exists saladRecipes.role (
+        id varchar(36) default (uuid()) primary key,
+        name varchar(25) not null unique
+    );
+
+create table if not exists saladRecipes.permission (
+        id varchar(36) default (uuid()) primary key,
+        name varchar(64) not null unique,
+        description varchar(256) not null default ''
+    );
+
+create table if not exists saladRecipes.rolePermission (
+        roleId varchar(36) not null,
+        permissionId varchar(36) not null,
+        primary key (roleId, permissionId),
+        foreign key (roleId) references saladRecipes.role(id) on delete cascade,
+        foreign key (permissionId) references saladRecipes.permission(id) on delete cascade
+    );
+
+create table if not exists saladRecipes.userRole (
+        userId varchar(36) not null,
+        roleId varchar(36) not null,
+        primary key (userId, roleId),
+        foreign key (userId) references saladRecipes.user(id) on delete cascade,
+        foreign key (roleId) references saladRecipes.role(id) on delete cascade
+    );
+
+insert into saladRecipes.role(name)
+values ('user'),
+       ('moderator'),
+       ('admin');
+
+insert into saladRecipes.permission(name, description)
+values ('recipe.create', 'создание рецептов'),
+       ('recipe.publish', 'публикация рецептов'),
+       ('comment.create', 'написание комментариев'),
+       ('comment.moderate', 'скрытие и проверка комментариев'),
+       ('report.resolve', 'рассмотрение жалоб'),
+       ('keyword.edit', 'редактирование запрещённых слов'),
+       ('catalog.edit', 'редактирование ингредиентов, единиц измерения и типов'),
+       ('user.manage', 'управление пользователями и ролями');
+
+insert into saladRecipes.rolePermission(roleId, permissionId)
+select role.id, permission.id
+from saladRecipes.role
+    join saladRecipes.permission
+where (role.name = 'user' and permission.name in ('recipe.create', 'comment.create'))
+   or (role.name = 'moderator' and permission.name in ('recipe.create', 'recipe.publish', 'comment.create',
+                                                        'comment.moderate', 'report.resolve', 'keyword.edit'))
+   or role.name = 'admin';
+
+insert into saladRecipes.userRole(userId, roleId)
+select user.id, role.id
+from saladRecipes.user
+    join saladRecipes.role on role.name = user.role;
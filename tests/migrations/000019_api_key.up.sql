@@ -0,0 +1,15 @@
+create table if not exists saladRecipes.apiKey (
+        id varchar(36) default (uuid()) primary key,
+        userId varchar(36) not null,
+        name varchar(64) not null default '',
+        prefix varchar(16) not null,
+        keyHash char(64) not null unique,
+        scopes json null,
+        createdAt datetime not null default current_timestamp,
+        expiresAt datetime null,
+        lastUsedAt datetime null,
+        revokedAt datetime null,
+        index (prefix),
+        index (userId),
+        foreign key (userId) references saladRecipes.user(id) on delete cascade
+    );
@@ -0,0 +1,4 @@
+# Email verification tokens are bound to the address they were sent to.
+# Tokens issued before this have no address and are no longer accepted
+alter table saladRecipes.oneTimeToken
+    add column emailHash char(64) null;
@@ -0,0 +1,4 @@
+# recipeIngredient.measurement defaults to this id, so links created without
+# a measurement need the row to exist
+insert into saladRecipes.measurement(id, name, grams)
+values ('01000000-0000-0000-0000-000000000000', 'по вкусу', null);
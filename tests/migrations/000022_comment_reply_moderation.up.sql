@@ -0,0 +1,4 @@
+alter table saladRecipes.commentReply
+    add column moderation int not null default 0, add check ( moderation between 0 and 2 ),
+    add column moderationReason varchar(256) not null default '',
+    add column moderatedAt datetime null;
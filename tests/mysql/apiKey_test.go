@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_apiKeyRepository_Create(t *testing.T) {
+	repo := mysql.NewApiKeyRepository(testDbInstance)
+	userId := newTestUser(t)
+	keyId := uuid.New()
+
+	tests := []struct {
+		name    string
+		key     *rDomain.ApiKey
+		wantErr bool
+		errIs   error
+		scopes  []string
+	}{
+		{
+			name: "ключ без прав",
+			key: &rDomain.ApiKey{
+				ID:     keyId,
+				UserID: userId,
+				Name:   "empty",
+			},
+			scopes: []string{},
+		}, // ключ без прав
+		{
+			name: "ключ с правами",
+			key: &rDomain.ApiKey{
+				UserID: userId,
+				Name:   "scoped",
+				Scopes: []string{rDomain.PermissionCreateRecipe},
+			},
+			scopes: []string{rDomain.PermissionCreateRecipe},
+		}, // ключ с правами
+		{
+			name: "повтор создания",
+			key: &rDomain.ApiKey{
+				ID:     keyId,
+				UserID: userId,
+				Name:   "empty",
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrAlreadyExists,
+		}, // повтор создания
+		{
+			name: "несуществующий пользователь",
+			key: &rDomain.ApiKey{
+				UserID: uuid.New(),
+				Name:   "orphan",
+			},
+			wantErr: true,
+		}, // несуществующий пользователь
+	}
+	plaintexts := make(map[string]struct{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := repo.Create(context.Background(), tt.key)
+
+			if tt.wantErr {
+				require.NotNil(t, err)
+				if tt.errIs != nil {
+					require.True(t, errors.Is(err, tt.errIs))
+				}
+				return
+			}
+			require.Nil(t, err)
+
+			require.True(t, strings.HasPrefix(created.Plaintext, rDomain.ApiKeyPrefix))
+			require.Equal(t, created.Plaintext[:rDomain.ApiKeyPrefixLength], created.Key.Prefix)
+			require.NotContains(t, plaintexts, created.Plaintext)
+			plaintexts[created.Plaintext] = struct{}{}
+
+			var stored []string
+			err = testDbInstance.Table("apiKey").
+				Where("id = ?", created.Key.ID).
+				Pluck("keyHash", &stored).Error
+			require.Nil(t, err)
+			require.Len(t, stored, 1)
+			require.NotEqual(t, created.Plaintext, stored[0])
+
+			res, err := repo.GetByKey(context.Background(), created.Plaintext)
+			require.Nil(t, err)
+			require.Equal(t, created.Key.ID, res.ID)
+			require.Equal(t, tt.scopes, res.Scopes)
+		})
+	}
+
+	keys, err := repo.GetByUser(context.Background(), userId)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(keys))
+}
+
+func Test_apiKeyRepository_GetByKey(t *testing.T) {
+	repo := mysql.NewApiKeyRepository(testDbInstance)
+	userId := newTestUser(t)
+	expiresAt := time.Now().Add(-time.Minute)
+	valid := newTestApiKey(t, userId, nil)
+	expired := newTestApiKey(t, userId, &expiresAt)
+	revoked := newTestApiKey(t, userId, nil)
+	require.Nil(t, repo.Revoke(context.Background(), userId, revoked.Key.ID))
+
+	// same public prefix, so the lookup reaches the hash comparison
+	lastChar := "A"
+	if strings.HasSuffix(valid.Plaintext, lastChar) {
+		lastChar = "B"
+	}
+	guessed := valid.Plaintext[:len(valid.Plaintext)-1] + lastChar
+
+	tests := []struct {
+		name      string
+		plaintext string
+		expected  uuid.UUID
+		wantErr   bool
+		errIs     error
+	}{
+		{
+			name:      "успешный поиск",
+			plaintext: valid.Plaintext,
+			expected:  valid.Key.ID,
+		}, // успешный поиск
+		{
+			name:      "совпадает только префикс",
+			plaintext: guessed,
+			wantErr:   true,
+			errIs:     rDomain.ErrApiKeyNotFound,
+		}, // совпадает только префикс
+		{
+			name:      "чужой формат",
+			plaintext: "xx_" + valid.Plaintext[len(rDomain.ApiKeyPrefix):],
+			wantErr:   true,
+			errIs:     rDomain.ErrApiKeyNotFound,
+		}, // чужой формат
+		{
+			name:      "слишком короткий ключ",
+			plaintext: rDomain.ApiKeyPrefix + "abc",
+			wantErr:   true,
+			errIs:     rDomain.ErrApiKeyNotFound,
+		}, // слишком короткий ключ
+		{
+			name:      "истекший ключ",
+			plaintext: expired.Plaintext,
+			wantErr:   true,
+			errIs:     rDomain.ErrApiKeyExpired,
+		}, // истекший ключ
+		{
+			name:      "отозванный ключ",
+			plaintext: revoked.Plaintext,
+			wantErr:   true,
+			errIs:     rDomain.ErrApiKeyRevoked,
+		}, // отозванный ключ
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().Truncate(time.Second)
+			res, err := repo.GetByKey(context.Background(), tt.plaintext)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, res.ID)
+				require.NotNil(t, res.LastUsedAt)
+				require.Empty(t, res.KeyHash)
+
+				keys, err := repo.GetByUser(context.Background(), userId)
+				require.Nil(t, err)
+				for _, key := range keys {
+					if key.ID == tt.expected {
+						require.NotNil(t, key.LastUsedAt)
+						require.False(t, key.LastUsedAt.Before(before))
+					} else {
+						require.Nil(t, key.LastUsedAt)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_apiKeyRepository_Revoke(t *testing.T) {
+	repo := mysql.NewApiKeyRepository(testDbInstance)
+	userId := newTestUser(t)
+	otherUserId := newTestUser(t)
+	key := newTestApiKey(t, userId, nil)
+
+	tests := []struct {
+		name    string
+		userId  uuid.UUID
+		keyId   uuid.UUID
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "чужой ключ",
+			userId:  otherUserId,
+			keyId:   key.Key.ID,
+			wantErr: true,
+			errIs:   rDomain.ErrApiKeyNotFound,
+		}, // чужой ключ
+		{
+			name:   "успешный отзыв",
+			userId: userId,
+			keyId:  key.Key.ID,
+		}, // успешный отзыв
+		{
+			name:   "повторный отзыв",
+			userId: userId,
+			keyId:  key.Key.ID,
+		}, // повторный отзыв
+		{
+			name:    "несуществующий ключ",
+			userId:  userId,
+			keyId:   uuid.New(),
+			wantErr: true,
+			errIs:   rDomain.ErrApiKeyNotFound,
+		}, // несуществующий ключ
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Revoke(context.Background(), tt.userId, tt.keyId)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				_, err = repo.GetByKey(context.Background(), key.Plaintext)
+				require.True(t, errors.Is(err, rDomain.ErrApiKeyRevoked))
+			}
+		})
+	}
+}
+
+func Test_apiKeyRepository_GetByUser(t *testing.T) {
+	repo := mysql.NewApiKeyRepository(testDbInstance)
+	userId := newTestUser(t)
+	first := newTestApiKey(t, userId, nil)
+	second := newTestApiKey(t, userId, nil)
+	otherUserId := newTestUser(t)
+	newTestApiKey(t, otherUserId, nil)
+
+	tests := []struct {
+		name     string
+		userId   uuid.UUID
+		expected []uuid.UUID
+	}{
+		{
+			name:     "ключи пользователя",
+			userId:   userId,
+			expected: []uuid.UUID{first.Key.ID, second.Key.ID},
+		}, // ключи пользователя
+		{
+			name:     "нет ключей",
+			userId:   uuid.New(),
+			expected: []uuid.UUID{},
+		}, // нет ключей
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := repo.GetByUser(context.Background(), tt.userId)
+			require.Nil(t, err)
+
+			ids := make([]uuid.UUID, 0, len(keys))
+			for _, key := range keys {
+				ids = append(ids, key.ID)
+				require.Empty(t, key.KeyHash)
+				require.Equal(t, tt.userId, key.UserID)
+			}
+			require.ElementsMatch(t, tt.expected, ids)
+		})
+	}
+}
@@ -3,8 +3,10 @@ package tests
 import (
 	"context"
 	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
 	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"net/mail"
 	"testing"
@@ -63,12 +65,13 @@ func TestAuthRepository_Register(t *testing.T) {
 	}
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := repo.Register(context.Background(), tt.authInfo)
+			id, err := repo.Register(context.Background(), tt.authInfo)
 
 			if tt.wantErr {
 				require.Equal(t, tt.errStr.Error(), err.Error())
 			} else {
 				require.Nil(t, err)
+				require.NotEqual(t, uuid.Nil, id)
 			}
 		})
 	}
@@ -113,3 +116,238 @@ func TestAuthRepository_GetByUsername(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthRepository_GetByEmail(t *testing.T) {
+	repo := mysql.NewAuthRepository(testDbInstance)
+
+	testCases := []struct {
+		name     string
+		email    string
+		expected *domain.UserAuth
+		wantErr  bool
+		errStr   error
+	}{
+		{
+			name:  "пользователь существует",
+			email: "existingMail@mail.ru",
+			expected: &domain.UserAuth{
+				HashedPass: "pass",
+			},
+			wantErr: false,
+		}, // пользователь существует
+		{
+			name:    "пользователь не найден",
+			email:   "notFound@mail.ru",
+			wantErr: true,
+			errStr:  errors.New("getting user by email: record not found"),
+		}, // пользователь не найден
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.GetByEmail(context.Background(), tt.email)
+
+			if tt.wantErr {
+				require.Equal(t, tt.errStr.Error(), err.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected.HashedPass, res.HashedPass)
+			}
+		})
+	}
+}
+
+func TestAuthRepository_GetByLogin(t *testing.T) {
+	repo := mysql.NewAuthRepository(testDbInstance)
+
+	existing, err := repo.GetByUsername(context.Background(), "anotherUsername")
+	require.Nil(t, err)
+	err = testDbInstance.Exec(`insert into saladRecipes.user(id, name, email, login, password, loginCollisionOf)
+		values (?, 'collidedUser', 'collidedMail@mail.ru', ' anotherUsername ', 'collided', ?)`,
+		uuid.New(), existing.ID).Error
+	require.Nil(t, err)
+
+	testCases := []struct {
+		name     string
+		login    string
+		expected *domain.UserAuth
+		wantErr  bool
+		errStr   error
+	}{
+		{
+			name:  "логин в другом регистре",
+			login: "AnotherUsername",
+			expected: &domain.UserAuth{
+				HashedPass: "pass",
+			},
+			wantErr: false,
+		}, // логин в другом регистре
+		{
+			name:  "вход по почте",
+			login: " EXISTINGMAIL@mail.ru ",
+			expected: &domain.UserAuth{
+				HashedPass: "pass",
+			},
+			wantErr: false,
+		}, // вход по почте
+		{
+			name:  "точное совпадение с логином из коллизии",
+			login: " anotherUsername ",
+			expected: &domain.UserAuth{
+				HashedPass: "collided",
+			},
+			wantErr: false,
+		}, // точное совпадение с логином из коллизии
+		{
+			name:  "коллизия без точного совпадения",
+			login: "ANOTHERUSERNAME",
+			expected: &domain.UserAuth{
+				HashedPass: "pass",
+			},
+			wantErr: false,
+		}, // коллизия без точного совпадения
+		{
+			name:    "пользователь не найден",
+			login:   "notFound",
+			wantErr: true,
+			errStr:  errors.New("getting user by login: record not found"),
+		}, // пользователь не найден
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.GetByLogin(context.Background(), tt.login)
+
+			if tt.wantErr {
+				require.Equal(t, tt.errStr.Error(), err.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected.HashedPass, res.HashedPass)
+			}
+		})
+	}
+}
+
+func TestAuthRepository_UpdatePasswordHash(t *testing.T) {
+	repo := mysql.NewAuthRepository(testDbInstance)
+
+	user, err := repo.GetByUsername(context.Background(), "testingUser")
+	require.Nil(t, err)
+
+	newHash := &rDomain.PasswordHash{
+		Hash:      "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+		Algorithm: rDomain.PasswordAlgorithmArgon2id,
+		Params: rDomain.PasswordHashParams{
+			Memory:      65536,
+			Iterations:  3,
+			Parallelism: 2,
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		oldHash string
+		wantErr bool
+		errStr  error
+	}{
+		{
+			name:    "устаревший хеш",
+			oldHash: "anotherHash",
+			wantErr: true,
+			errStr:  errors.New("updating password hash: password hash was changed concurrently"),
+		}, // устаревший хеш
+		{
+			name:    "успешное обновление",
+			oldHash: user.HashedPass,
+			wantErr: false,
+		}, // успешное обновление
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.UpdatePasswordHash(context.Background(), user.ID, tt.oldHash, newHash)
+
+			if tt.wantErr {
+				require.Equal(t, tt.errStr.Error(), err.Error())
+			} else {
+				require.Nil(t, err)
+				res, err := repo.GetByUsername(context.Background(), "testingUser")
+				require.Nil(t, err)
+				require.Equal(t, newHash.Hash, res.HashedPass)
+				require.Equal(t, newHash.Algorithm, res.HashAlgorithm)
+				require.Equal(t, newHash.Params, res.HashParams)
+			}
+		})
+	}
+}
+
+func TestAuthRepository_PasswordHashInfo(t *testing.T) {
+	authRepo := mysql.NewAuthRepository(testDbInstance)
+	userRepo := mysql.NewUserRepository(testDbInstance)
+	suffix := uuid.NewString()[:8]
+	user := &domain.User{
+		Name:     "hashUser" + suffix,
+		Username: "hashUser" + suffix,
+		Email:    mail.Address{Address: "hashUser" + suffix + "@mail.ru"},
+	}
+
+	tests := []struct {
+		name      string
+		write     func(password string) error
+		password  string
+		algorithm string
+		params    rDomain.PasswordHashParams
+	}{
+		{
+			name: "регистрация с bcrypt",
+			write: func(password string) error {
+				user.Password = password
+				id, err := authRepo.Register(context.Background(), user)
+				user.ID = id
+				return err
+			},
+			password:  "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			algorithm: rDomain.PasswordAlgorithmBcrypt,
+			params:    rDomain.PasswordHashParams{Cost: 10},
+		}, // регистрация с bcrypt
+		{
+			name: "обновление на argon2id",
+			write: func(password string) error {
+				user.Password = password
+				return userRepo.Update(context.Background(), user)
+			},
+			password:  "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+			algorithm: rDomain.PasswordAlgorithmArgon2id,
+			params: rDomain.PasswordHashParams{
+				Memory:      65536,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+		}, // обновление на argon2id
+		{
+			name: "создание с неизвестным форматом",
+			write: func(password string) error {
+				user.ID = uuid.Nil
+				user.Username = "hashCreated" + suffix
+				user.Email = mail.Address{Address: "hashCreated" + suffix + "@mail.ru"}
+				user.Password = password
+				id, err := userRepo.Create(context.Background(), user)
+				user.ID = id
+				return err
+			},
+			password:  "plain",
+			algorithm: rDomain.PasswordAlgorithmUnknown,
+		}, // создание с неизвестным форматом
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.write(tt.password)
+			require.Nil(t, err)
+
+			res, err := authRepo.GetByUsername(context.Background(), user.Username)
+			require.Nil(t, err)
+			require.Equal(t, tt.password, res.HashedPass)
+			require.Equal(t, tt.algorithm, res.HashAlgorithm)
+			require.Equal(t, tt.params, res.HashParams)
+		})
+	}
+}
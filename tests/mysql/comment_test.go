@@ -0,0 +1,635 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func Test_commentRepository_Replies(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	commentId := newTestComment(t, saladId, 5)
+	otherCommentId := newTestComment(t, saladId, 4)
+	authorId := newTestUser(t)
+
+	reply := func(commentId uuid.UUID, parentId uuid.UUID) (uuid.UUID, error) {
+		return repo.Reply(context.Background(), &rDomain.CommentReply{
+			CommentID: commentId,
+			ParentID:  uuid.NullUUID{UUID: parentId, Valid: parentId != uuid.Nil},
+			AuthorID:  authorId,
+			Text:      "reply",
+		})
+	}
+	rootId, err := reply(commentId, uuid.Nil)
+	require.Nil(t, err)
+	secondRootId, err := reply(commentId, uuid.Nil)
+	require.Nil(t, err)
+	chain := []uuid.UUID{rootId}
+	for len(chain) < rDomain.MaxReplyDepth {
+		id, err := reply(commentId, chain[len(chain)-1])
+		require.Nil(t, err)
+		chain = append(chain, id)
+	}
+
+	tests := []struct {
+		name     string
+		comment  uuid.UUID
+		parent   uuid.UUID
+		wantErr  bool
+		errIs    error
+		expected int
+	}{
+		{
+			name:     "ответ на ответ",
+			comment:  commentId,
+			parent:   secondRootId,
+			expected: 2,
+		}, // ответ на ответ
+		{
+			name:    "слишком глубокий ответ",
+			comment: commentId,
+			parent:  chain[len(chain)-1],
+			wantErr: true,
+			errIs:   rDomain.ErrReplyTooDeep,
+		}, // слишком глубокий ответ
+		{
+			name:    "родитель из другого комментария",
+			comment: otherCommentId,
+			parent:  rootId,
+			wantErr: true,
+			errIs:   rDomain.ErrForeignReplyRoot,
+		}, // родитель из другого комментария
+		{
+			name:    "несуществующий родитель",
+			comment: commentId,
+			parent:  uuid.New(),
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий родитель
+		{
+			name:    "несуществующий комментарий",
+			comment: uuid.New(),
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий комментарий
+	}
+	replies := []uuid.UUID{rootId, secondRootId}
+	replies = append(replies, chain[1:]...)
+	children := map[uuid.UUID][]uuid.UUID{uuid.Nil: {rootId, secondRootId}}
+	for i := 1; i < len(chain); i++ {
+		children[chain[i-1]] = []uuid.UUID{chain[i]}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := reply(tt.comment, tt.parent)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				return
+			}
+			require.Nil(t, err)
+			replies = append(replies, id)
+			children[tt.parent] = append(children[tt.parent], id)
+			var depths []int
+			err = testDbInstance.Model(&rDomain.CommentReply{}).
+				Where("id = ?", id).
+				Pluck("depth", &depths).Error
+			require.Nil(t, err)
+			require.Equal(t, []int{tt.expected}, depths)
+		})
+	}
+
+	count, err := repo.CountReplies(context.Background(), commentId)
+	require.Nil(t, err)
+	require.Equal(t, len(replies), count)
+	count, err = repo.CountReplies(context.Background(), otherCommentId)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	threads, pages, err := repo.GetThreads(context.Background(), saladId, nil, 1)
+	require.Nil(t, err)
+	require.Equal(t, 1, pages)
+	require.Equal(t, 2, len(threads))
+	for _, thread := range threads {
+		if thread.Comment.ID == otherCommentId {
+			require.Equal(t, 0, thread.RepliesCount)
+			require.Equal(t, 0, len(thread.Replies))
+			continue
+		}
+		require.Equal(t, commentId, thread.Comment.ID)
+		require.Equal(t, len(replies), thread.RepliesCount)
+
+		got := make(map[uuid.UUID][]uuid.UUID)
+		var walk func(parentId uuid.UUID, nodes []*rDomain.ReplyNode)
+		walk = func(parentId uuid.UUID, nodes []*rDomain.ReplyNode) {
+			for _, node := range nodes {
+				got[parentId] = append(got[parentId], node.Reply.ID)
+				walk(node.Reply.ID, node.Replies)
+			}
+		}
+		walk(uuid.Nil, thread.Replies)
+		require.Equal(t, len(children), len(got))
+		for parentId, ids := range children {
+			require.ElementsMatch(t, ids, got[parentId])
+		}
+	}
+}
+
+func Test_commentRepository_ThreadsModeration(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	visibleId := newTestComment(t, saladId, 5)
+	hiddenId := newTestComment(t, saladId, 1)
+	authorId := newTestUser(t)
+
+	reply := func(parentId uuid.UUID) uuid.UUID {
+		id, err := repo.Reply(context.Background(), &rDomain.CommentReply{
+			CommentID: visibleId,
+			ParentID:  uuid.NullUUID{UUID: parentId, Valid: parentId != uuid.Nil},
+			AuthorID:  authorId,
+			Text:      "reply",
+		})
+		require.Nil(t, err)
+		return id
+	}
+	shownId := reply(uuid.Nil)
+	flaggedId := reply(uuid.Nil)
+	childId := reply(flaggedId)
+	require.Nil(t, repo.SetModeration(context.Background(), hiddenId, rDomain.CommentHidden, "spam"))
+	require.Nil(t, repo.SetReplyModeration(context.Background(), flaggedId, rDomain.CommentFlagged, "spam"))
+
+	err := repo.SetReplyModeration(context.Background(), uuid.New(), rDomain.CommentHidden, "")
+	require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	err = repo.SetReplyModeration(context.Background(), shownId, rDomain.CommentHidden+1, "")
+	require.True(t, errors.Is(err, rDomain.ErrUnknownModeration))
+
+	tests := []struct {
+		name     string
+		opts     *rDomain.CommentListOptions
+		comments map[uuid.UUID]int
+		replies  []uuid.UUID
+	}{
+		{
+			name:     "без модерированных",
+			opts:     nil,
+			comments: map[uuid.UUID]int{visibleId: rDomain.CommentVisible},
+			replies:  []uuid.UUID{shownId},
+		}, // без модерированных
+		{
+			name:     "автор ответов",
+			opts:     &rDomain.CommentListOptions{ViewerID: authorId},
+			comments: map[uuid.UUID]int{visibleId: rDomain.CommentVisible},
+			replies:  []uuid.UUID{shownId, flaggedId, childId},
+		}, // автор ответов
+		{
+			name: "модератор",
+			opts: &rDomain.CommentListOptions{IncludeModerated: true},
+			comments: map[uuid.UUID]int{
+				visibleId: rDomain.CommentVisible,
+				hiddenId:  rDomain.CommentHidden,
+			},
+			replies: []uuid.UUID{shownId, flaggedId, childId},
+		}, // модератор
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threads, _, err := repo.GetThreads(context.Background(), saladId, tt.opts, 1)
+			require.Nil(t, err)
+
+			comments := make(map[uuid.UUID]int)
+			replies := make([]uuid.UUID, 0)
+			var walk func(nodes []*rDomain.ReplyNode)
+			walk = func(nodes []*rDomain.ReplyNode) {
+				for _, node := range nodes {
+					replies = append(replies, node.Reply.ID)
+					walk(node.Replies)
+				}
+			}
+			for _, thread := range threads {
+				comments[thread.Comment.ID] = thread.Moderation
+				before := len(replies)
+				walk(thread.Replies)
+				require.Equal(t, len(replies)-before, thread.RepliesCount)
+			}
+			require.Equal(t, tt.comments, comments)
+			require.ElementsMatch(t, tt.replies, replies)
+		})
+	}
+}
+
+func ratedComment(t *testing.T, saladId uuid.UUID, commentId uuid.UUID) *rDomain.RatedComment {
+	comments, _, err := mysql.NewCommentRepository(testDbInstance).GetAllBySaladIDSorted(context.Background(), saladId,
+		&rDomain.CommentListOptions{IncludeModerated: true}, 1)
+	require.Nil(t, err)
+	for _, comment := range comments {
+		if comment.Comment.ID == commentId {
+			return comment
+		}
+	}
+	require.FailNow(t, "comment not found")
+	return nil
+}
+
+func Test_commentRepository_Vote(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	commentId := newTestComment(t, saladId, 5)
+	firstVoterId := newTestUser(t)
+	secondVoterId := newTestUser(t)
+
+	tests := []struct {
+		name      string
+		comment   uuid.UUID
+		userId    uuid.UUID
+		remove    bool
+		helpful   bool
+		wantErr   bool
+		helpfulN  int
+		unhelpful int
+	}{
+		{
+			name:     "полезный",
+			comment:  commentId,
+			userId:   firstVoterId,
+			helpful:  true,
+			helpfulN: 1,
+		}, // полезный
+		{
+			name:      "бесполезный от другого пользователя",
+			comment:   commentId,
+			userId:    secondVoterId,
+			helpful:   false,
+			helpfulN:  1,
+			unhelpful: 1,
+		}, // бесполезный от другого пользователя
+		{
+			name:     "изменение голоса",
+			comment:  commentId,
+			userId:   secondVoterId,
+			helpful:  true,
+			helpfulN: 2,
+		}, // изменение голоса
+		{
+			name:     "повторный голос",
+			comment:  commentId,
+			userId:   secondVoterId,
+			helpful:  true,
+			helpfulN: 2,
+		}, // повторный голос
+		{
+			name:     "отмена голоса",
+			comment:  commentId,
+			userId:   firstVoterId,
+			remove:   true,
+			helpfulN: 1,
+		}, // отмена голоса
+		{
+			name:     "отмена несуществующего голоса",
+			comment:  commentId,
+			userId:   firstVoterId,
+			remove:   true,
+			helpfulN: 1,
+		}, // отмена несуществующего голоса
+		{
+			name:     "несуществующий пользователь",
+			comment:  commentId,
+			userId:   uuid.New(),
+			helpful:  true,
+			wantErr:  true,
+			helpfulN: 1,
+		}, // несуществующий пользователь
+		{
+			name:     "несуществующий комментарий",
+			comment:  uuid.New(),
+			userId:   firstVoterId,
+			helpful:  true,
+			wantErr:  true,
+			helpfulN: 1,
+		}, // несуществующий комментарий
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.remove {
+				err = repo.RemoveVote(context.Background(), tt.comment, tt.userId)
+			} else {
+				err = repo.Vote(context.Background(), tt.comment, tt.userId, tt.helpful)
+			}
+
+			if tt.wantErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			comment := ratedComment(t, saladId, commentId)
+			require.Equal(t, tt.helpfulN, comment.Helpful)
+			require.Equal(t, tt.unhelpful, comment.Unhelpful)
+		})
+	}
+}
+
+func Test_commentRepository_GetAllBySaladIDSorted(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	bestId := newTestComment(t, saladId, 5)
+	worstId := newTestComment(t, saladId, 2)
+	middleId := newTestComment(t, saladId, 4)
+	hiddenId := newTestComment(t, saladId, 3)
+	for _, vote := range []struct {
+		commentId uuid.UUID
+		helpful   bool
+	}{
+		{worstId, true},
+		{worstId, true},
+		{middleId, true},
+		{middleId, false},
+	} {
+		err := repo.Vote(context.Background(), vote.commentId, newTestUser(t), vote.helpful)
+		require.Nil(t, err)
+	}
+	err := repo.SetModeration(context.Background(), hiddenId, rDomain.CommentHidden, "spam")
+	require.Nil(t, err)
+	hidden, err := repo.GetById(context.Background(), hiddenId)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		opts     *rDomain.CommentListOptions
+		expected []uuid.UUID
+	}{
+		{
+			name:     "сначала высокие оценки",
+			opts:     &rDomain.CommentListOptions{Sort: rDomain.CommentSortHighestRating},
+			expected: []uuid.UUID{bestId, middleId, worstId},
+		}, // сначала высокие оценки
+		{
+			name:     "сначала низкие оценки",
+			opts:     &rDomain.CommentListOptions{Sort: rDomain.CommentSortLowestRating},
+			expected: []uuid.UUID{worstId, middleId, bestId},
+		}, // сначала низкие оценки
+		{
+			name:     "сначала полезные",
+			opts:     &rDomain.CommentListOptions{Sort: rDomain.CommentSortMostHelpful},
+			expected: []uuid.UUID{worstId, middleId, bestId},
+		}, // сначала полезные
+		{
+			name: "по оценке",
+			opts: &rDomain.CommentListOptions{
+				Sort:   rDomain.CommentSortHighestRating,
+				Rating: 4,
+			},
+			expected: []uuid.UUID{middleId},
+		}, // по оценке
+		{
+			name: "скрытый виден автору",
+			opts: &rDomain.CommentListOptions{
+				Sort:     rDomain.CommentSortHighestRating,
+				ViewerID: hidden.AuthorID,
+			},
+			expected: []uuid.UUID{bestId, middleId, hiddenId, worstId},
+		}, // скрытый виден автору
+		{
+			name: "с модерируемыми",
+			opts: &rDomain.CommentListOptions{
+				Sort:             rDomain.CommentSortLowestRating,
+				IncludeModerated: true,
+			},
+			expected: []uuid.UUID{worstId, hiddenId, middleId, bestId},
+		}, // с модерируемыми
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments, pages, err := repo.GetAllBySaladIDSorted(context.Background(), saladId, tt.opts, 1)
+			require.Nil(t, err)
+			require.Equal(t, 1, pages)
+
+			ids := make([]uuid.UUID, 0)
+			for _, comment := range comments {
+				ids = append(ids, comment.Comment.ID)
+			}
+			require.Equal(t, tt.expected, ids)
+		})
+	}
+
+	comment := ratedComment(t, saladId, middleId)
+	require.Equal(t, 1, comment.Helpful)
+	require.Equal(t, 1, comment.Unhelpful)
+}
+
+func Test_commentRepository_UpdateRevisions(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	commentId := newTestComment(t, saladId, 5)
+	comment, err := repo.GetById(context.Background(), commentId)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		commentId uuid.UUID
+		text      string
+		rating    int
+		wantErr   bool
+		errIs     error
+		expected  []string
+	}{
+		{
+			name:      "изменение текста",
+			commentId: commentId,
+			text:      "edited",
+			rating:    5,
+			expected:  []string{"comment"},
+		}, // изменение текста
+		{
+			name:      "без изменений",
+			commentId: commentId,
+			text:      "edited",
+			rating:    5,
+			expected:  []string{"comment"},
+		}, // без изменений
+		{
+			name:      "изменение оценки",
+			commentId: commentId,
+			text:      "edited",
+			rating:    3,
+			expected:  []string{"edited", "comment"},
+		}, // изменение оценки
+		{
+			name:      "несуществующий комментарий",
+			commentId: uuid.New(),
+			text:      "edited",
+			rating:    3,
+			wantErr:   true,
+			errIs:     gorm.ErrRecordNotFound,
+		}, // несуществующий комментарий
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Update(context.Background(), &domain.Comment{
+				ID:       tt.commentId,
+				AuthorID: comment.AuthorID,
+				SaladID:  saladId,
+				Text:     tt.text,
+				Rating:   tt.rating,
+			})
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				revisions, err := repo.GetRevisions(context.Background(), tt.commentId)
+				require.Nil(t, err)
+				require.Equal(t, 0, len(revisions))
+				return
+			}
+			require.Nil(t, err)
+			revisions, err := repo.GetRevisions(context.Background(), commentId)
+			require.Nil(t, err)
+			texts := make([]string, 0, len(revisions))
+			for _, revision := range revisions {
+				texts = append(texts, revision.Text)
+			}
+			// editedAt is stored to the second, so revisions of one test
+			// may share it and come in any order
+			require.ElementsMatch(t, tt.expected, texts)
+		})
+	}
+}
+
+func Test_commentRepository_SetModeration(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	commentId := newTestComment(t, saladId, 5)
+
+	tests := []struct {
+		name       string
+		commentId  uuid.UUID
+		moderation int
+		reason     string
+		wantErr    bool
+		errIs      error
+		visible    bool
+		flagged    bool
+	}{
+		{
+			name:       "пометка",
+			commentId:  commentId,
+			moderation: rDomain.CommentFlagged,
+			reason:     "оскорбление",
+			flagged:    true,
+		}, // пометка
+		{
+			name:       "повторная пометка",
+			commentId:  commentId,
+			moderation: rDomain.CommentFlagged,
+			reason:     "оскорбление",
+			flagged:    true,
+		}, // повторная пометка
+		{
+			name:       "скрытие",
+			commentId:  commentId,
+			moderation: rDomain.CommentHidden,
+			reason:     "спам",
+		}, // скрытие
+		{
+			name:       "неизвестное состояние",
+			commentId:  commentId,
+			moderation: rDomain.CommentHidden + 1,
+			wantErr:    true,
+			errIs:      rDomain.ErrUnknownModeration,
+		}, // неизвестное состояние
+		{
+			name:       "несуществующий комментарий",
+			commentId:  uuid.New(),
+			moderation: rDomain.CommentFlagged,
+			wantErr:    true,
+			errIs:      gorm.ErrRecordNotFound,
+		}, // несуществующий комментарий
+		{
+			name:       "возврат в видимые",
+			commentId:  commentId,
+			moderation: rDomain.CommentVisible,
+			visible:    true,
+		}, // возврат в видимые
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.SetModeration(context.Background(), tt.commentId, tt.moderation, tt.reason)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				return
+			}
+			require.Nil(t, err)
+
+			comments, _, err := repo.GetAllBySaladID(context.Background(), saladId, 1)
+			require.Nil(t, err)
+			require.Equal(t, tt.visible, len(comments) == 1)
+
+			flagged := flaggedComment(t, commentId)
+			require.Equal(t, tt.flagged, flagged != nil)
+			if tt.flagged {
+				require.Equal(t, tt.reason, flagged.Reason)
+				require.Equal(t, rDomain.CommentFlagged, flagged.Moderation)
+				require.False(t, flagged.ModeratedAt.IsZero())
+			}
+		})
+	}
+}
+
+func Test_commentRepository_GetFlagged(t *testing.T) {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	firstId := newTestComment(t, saladId, 5)
+	secondId := newTestComment(t, saladId, 4)
+	hiddenId := newTestComment(t, saladId, 3)
+	visibleId := newTestComment(t, saladId, 2)
+	for _, moderation := range []struct {
+		commentId  uuid.UUID
+		moderation int
+	}{
+		{firstId, rDomain.CommentFlagged},
+		{secondId, rDomain.CommentFlagged},
+		{hiddenId, rDomain.CommentHidden},
+	} {
+		err := repo.SetModeration(context.Background(), moderation.commentId, moderation.moderation, "reason")
+		require.Nil(t, err)
+	}
+
+	var ids []uuid.UUID
+	for page := 1; ; page++ {
+		comments, pages, err := repo.GetFlagged(context.Background(), page)
+		require.Nil(t, err)
+		for _, comment := range comments {
+			require.Equal(t, rDomain.CommentFlagged, comment.Moderation)
+			ids = append(ids, comment.Comment.ID)
+		}
+		if page >= pages {
+			break
+		}
+	}
+	require.Contains(t, ids, firstId)
+	require.Contains(t, ids, secondId)
+	require.NotContains(t, ids, hiddenId)
+	require.NotContains(t, ids, visibleId)
+}
+
+func flaggedComment(t *testing.T, commentId uuid.UUID) *rDomain.ModeratedComment {
+	repo := mysql.NewCommentRepository(testDbInstance)
+	for page := 1; ; page++ {
+		comments, pages, err := repo.GetFlagged(context.Background(), page)
+		require.Nil(t, err)
+		for _, comment := range comments {
+			if comment.Comment.ID == commentId {
+				return comment
+			}
+		}
+		if page >= pages {
+			return nil
+		}
+	}
+}
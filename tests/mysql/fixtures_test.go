@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+// newTestUser registers a user with a unique login and email
+func newTestUser(t *testing.T) uuid.UUID {
+	suffix := uuid.NewString()[:8]
+	id, err := mysql.NewAuthRepository(testDbInstance).Register(context.Background(), &domain.User{
+		Name:     "user" + suffix,
+		Username: "user" + suffix,
+		Password: "pass",
+		Email:    mail.Address{Address: "user" + suffix + "@mail.ru"},
+	})
+	require.Nil(t, err)
+	return id
+}
+
+// newTestRecipe creates a salad with a recipe that no other test touches.
+// The recipe stays in editing, so listings of published salads do not see it
+func newTestRecipe(t *testing.T, authorId uuid.UUID) (uuid.UUID, uuid.UUID) {
+	saladId, err := mysql.NewSaladRepository(testDbInstance).Create(context.Background(), &domain.Salad{
+		AuthorID: authorId,
+		Name:     "salad " + uuid.NewString()[:8],
+	})
+	require.Nil(t, err)
+	recipeId, err := mysql.NewRecipeRepository(testDbInstance).Create(context.Background(), &domain.Recipe{
+		SaladID:          saladId,
+		Status:           domain.EditingSaladStatus,
+		NumberOfServings: 1,
+		TimeToCook:       10,
+	})
+	require.Nil(t, err)
+	return saladId, recipeId
+}
+
+// newTestSteps adds steps with the given names to the recipe in order
+func newTestSteps(t *testing.T, recipeId uuid.UUID, names ...string) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		id, err := mysql.NewRecipeStepRepository(testDbInstance).Create(context.Background(), &domain.RecipeStep{
+			RecipeID:    recipeId,
+			Name:        name,
+			Description: "description",
+		})
+		require.Nil(t, err)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// newTestComment leaves a comment on the salad from a new user
+func newTestComment(t *testing.T, saladId uuid.UUID, rating int) uuid.UUID {
+	id, err := mysql.NewCommentRepository(testDbInstance).Create(context.Background(), &domain.Comment{
+		AuthorID: newTestUser(t),
+		SaladID:  saladId,
+		Text:     "comment",
+		Rating:   rating,
+	})
+	require.Nil(t, err)
+	return id
+}
+
+// newTestSession starts a session for the user and returns its refresh token
+func newTestSession(t *testing.T, userId uuid.UUID, expiresAt time.Time) (uuid.UUID, string) {
+	token := uuid.NewString()
+	id, err := mysql.NewSessionRepository(testDbInstance).Create(context.Background(), &rDomain.Session{
+		UserID:    userId,
+		Device:    "device",
+		UserAgent: "agent",
+		ExpiresAt: expiresAt,
+	}, token)
+	require.Nil(t, err)
+	return id, token
+}
+
+// newTestToken issues a token for the user and returns its plaintext
+func newTestToken(t *testing.T, userId uuid.UUID, purpose string, expiresAt time.Time) string {
+	plaintext := uuid.NewString()
+	_, err := mysql.NewOneTimeTokenRepository(testDbInstance).Create(context.Background(), &rDomain.OneTimeToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}, plaintext)
+	require.Nil(t, err)
+	return plaintext
+}
+
+// newTestApiKey creates a key of the user, expiresAt may be nil
+func newTestApiKey(t *testing.T, userId uuid.UUID, expiresAt *time.Time) *rDomain.CreatedApiKey {
+	created, err := mysql.NewApiKeyRepository(testDbInstance).Create(context.Background(), &rDomain.ApiKey{
+		UserID:    userId,
+		Name:      "key " + uuid.NewString()[:8],
+		ExpiresAt: expiresAt,
+	})
+	require.Nil(t, err)
+	return created
+}
+
+func idByName(t *testing.T, table string, name string) uuid.UUID {
+	var ids []uuid.UUID
+	err := testDbInstance.Table(table).
+		Where("name = ?", name).
+		Pluck("id", &ids).Error
+	require.Nil(t, err)
+	require.Equal(t, 1, len(ids))
+	return ids[0]
+}
@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func Test_ingredientRepository_Autocomplete(t *testing.T) {
+	repo := mysql.NewIngredientRepository(testDbInstance)
+
+	tests := []struct {
+		name     string
+		query    string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "совпадение по префиксу",
+			query:    "морк",
+			limit:    5,
+			expected: []string{"морковь"},
+		}, // совпадение по префиксу
+		{
+			name:     "опечатка в названии",
+			query:    "говяжина",
+			limit:    5,
+			expected: []string{"говядина"},
+		}, // опечатка в названии
+		{
+			name:     "регистр не учитывается",
+			query:    "ЛОСОСЬ",
+			limit:    5,
+			expected: []string{"лосось"},
+		}, // регистр не учитывается
+		{
+			name:     "совпадение по синониму",
+			query:    "семга",
+			limit:    5,
+			expected: []string{"лосось"},
+		}, // совпадение по синониму
+		{
+			name:     "нет совпадений",
+			query:    "xyz",
+			limit:    5,
+			expected: []string{},
+		}, // нет совпадений
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.Autocomplete(context.Background(), tt.query, tt.limit)
+			require.Nil(t, err)
+
+			names := make([]string, 0)
+			for _, ingredient := range res {
+				names = append(names, ingredient.Name)
+			}
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func Test_ingredientRepository_GetByAlias(t *testing.T) {
+	repo := mysql.NewIngredientRepository(testDbInstance)
+
+	tests := []struct {
+		name     string
+		alias    string
+		expected string
+		wantErr  bool
+		errStr   error
+	}{
+		{
+			name:     "поиск по синониму",
+			alias:    "морковка",
+			expected: "морковь",
+			wantErr:  false,
+		}, // поиск по синониму
+		{
+			name:     "поиск по основному названию",
+			alias:    "морковь",
+			expected: "морковь",
+			wantErr:  false,
+		}, // поиск по основному названию
+		{
+			name:    "синоним не найден",
+			alias:   "томат",
+			wantErr: true,
+			errStr:  errors.New("getting ingredient by alias: record not found"),
+		}, // синоним не найден
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.GetByAlias(context.Background(), tt.alias)
+
+			if tt.wantErr {
+				require.Equal(t, tt.errStr.Error(), err.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, res.Name)
+			}
+		})
+	}
+}
+
+func Test_ingredientRepository_AddAlias(t *testing.T) {
+	repo := mysql.NewIngredientRepository(testDbInstance)
+	ingredientId := idByName(t, "ingredient", "говядина")
+
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "пустой синоним",
+			alias:   "",
+			wantErr: true,
+			errIs:   rDomain.ErrEmptyAlias,
+		}, // пустой синоним
+		{
+			name:    "синоним из пробелов",
+			alias:   "   ",
+			wantErr: true,
+			errIs:   rDomain.ErrEmptyAlias,
+		}, // синоним из пробелов
+		{
+			name:    "успешное добавление",
+			alias:   " телятина ",
+			wantErr: false,
+		}, // успешное добавление
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.AddAlias(context.Background(), ingredientId, tt.alias)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				res, err := repo.Autocomplete(context.Background(), "телят", 5)
+				require.Nil(t, err)
+				require.Equal(t, 1, len(res))
+				require.Equal(t, ingredientId, res[0].ID)
+			}
+		})
+	}
+}
+
+func Test_ingredientRepository_Update(t *testing.T) {
+	repo := mysql.NewIngredientRepository(testDbInstance)
+	typeId := idByName(t, "ingredientType", "овощ")
+	id, err := repo.Create(context.Background(), &domain.Ingredient{TypeID: typeId, Name: "кабачок", Calories: 1})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name       string
+		ingredient *domain.Ingredient
+		query      string
+		expected   []uuid.UUID
+		wantErr    bool
+		errIs      error
+	}{
+		{
+			name:       "успешное обновление",
+			ingredient: &domain.Ingredient{ID: id, TypeID: typeId, Name: "цукини", Calories: 1},
+			query:      "цукин",
+			expected:   []uuid.UUID{id},
+			wantErr:    false,
+		}, // успешное обновление
+		{
+			name:       "несуществующий ингредиент",
+			ingredient: &domain.Ingredient{ID: uuid.New(), TypeID: typeId, Name: "патиссон", Calories: 1},
+			query:      "патиссон",
+			expected:   []uuid.UUID{},
+			wantErr:    true,
+			errIs:      gorm.ErrRecordNotFound,
+		}, // несуществующий ингредиент
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Update(context.Background(), tt.ingredient)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				_, err = repo.GetById(context.Background(), tt.ingredient.ID)
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			res, err := repo.Autocomplete(context.Background(), tt.query, 5)
+			require.Nil(t, err)
+			ids := make([]uuid.UUID, 0, len(res))
+			for _, ingredient := range res {
+				ids = append(ids, ingredient.ID)
+			}
+			require.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func Test_ingredientRepository_Merge(t *testing.T) {
+	repo := mysql.NewIngredientRepository(testDbInstance)
+	measurementRepo := mysql.NewMeasrementRepository(testDbInstance)
+	typeId := idByName(t, "ingredientType", "овощ")
+	grams := idByName(t, "measurement", "граммов")
+	kilograms := idByName(t, "measurement", "килограмм")
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	_, otherRecipeId := newTestRecipe(t, newTestUser(t))
+
+	newIngredient := func(name string) uuid.UUID {
+		id, err := repo.Create(context.Background(), &domain.Ingredient{TypeID: typeId, Name: name, Calories: 1})
+		require.Nil(t, err)
+		return id
+	}
+	link := func(recipeId uuid.UUID, ingredientId uuid.UUID, measurementId uuid.UUID, amount int) uuid.UUID {
+		linkId, err := repo.Link(context.Background(), recipeId, ingredientId)
+		require.Nil(t, err)
+		err = measurementRepo.UpdateLink(context.Background(), linkId, measurementId, amount)
+		require.Nil(t, err)
+		return linkId
+	}
+
+	keepId := newIngredient("огурец")
+	duplicateId := newIngredient("огурчик")
+	otherDuplicateId := newIngredient("огурцы")
+	keptLinkId := link(recipeId, keepId, kilograms, 1)
+	removedLinkId := link(recipeId, duplicateId, grams, 1)
+	repointedLinkId := link(otherRecipeId, otherDuplicateId, grams, 5)
+	stepRepo := mysql.NewRecipeStepRepository(testDbInstance)
+	stepIds := newTestSteps(t, recipeId, "both", "duplicate")
+	for _, stepLink := range []struct{ stepId, linkId uuid.UUID }{
+		{stepIds[0], keptLinkId},
+		{stepIds[0], removedLinkId},
+		{stepIds[1], removedLinkId},
+	} {
+		err := stepRepo.LinkIngredient(context.Background(), stepLink.stepId, stepLink.linkId)
+		require.Nil(t, err)
+	}
+
+	_, err := repo.Merge(context.Background(), keepId, []uuid.UUID{keepId})
+	require.True(t, errors.Is(err, rDomain.ErrMergeIntoItself))
+
+	report, err := repo.Merge(context.Background(), keepId, []uuid.UUID{duplicateId, otherDuplicateId})
+	require.Nil(t, err)
+	require.Equal(t, []uuid.UUID{duplicateId, otherDuplicateId}, report.DeletedIds)
+	require.Equal(t, []uuid.UUID{repointedLinkId}, report.RepointedLinks)
+	require.Equal(t, []*rDomain.MergedIngredientLink{{
+		RecipeId:          recipeId,
+		LinkId:            keptLinkId,
+		MeasurementBefore: kilograms,
+		MeasurementAdded:  grams,
+		Measurement:       grams,
+		AmountBefore:      1,
+		AmountAdded:       1,
+		AmountAfter:       1001,
+		RemovedLinkId:     removedLinkId,
+	}}, report.MergedLinks)
+
+	measurement, amount, err := measurementRepo.GetByRecipeId(context.Background(), keepId, recipeId)
+	require.Nil(t, err)
+	require.Equal(t, grams, measurement.ID)
+	require.Equal(t, 1001, amount)
+
+	measurement, amount, err = measurementRepo.GetByRecipeId(context.Background(), keepId, otherRecipeId)
+	require.Nil(t, err)
+	require.Equal(t, grams, measurement.ID)
+	require.Equal(t, 5, amount)
+
+	sections, err := stepRepo.GetSections(context.Background(), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sections))
+	for _, step := range sections[0].Steps {
+		require.Equal(t, 1, len(step.Ingredients))
+		require.Equal(t, keptLinkId, step.Ingredients[0].LinkId)
+		require.Equal(t, 1001, step.Ingredients[0].Amount)
+	}
+
+	versionRepo := mysql.NewRecipeVersionRepository(testDbInstance)
+	for _, tt := range []struct {
+		recipeId uuid.UUID
+		expected *rDomain.IngredientLinkSnapshot
+	}{
+		{recipeId, &rDomain.IngredientLinkSnapshot{
+			ID: keptLinkId, IngredientId: keepId, Measurement: grams, Amount: 1001}},
+		{otherRecipeId, &rDomain.IngredientLinkSnapshot{
+			ID: repointedLinkId, IngredientId: keepId, Measurement: grams, Amount: 5}},
+	} {
+		versions, err := versionRepo.GetAll(context.Background(), tt.recipeId)
+		require.Nil(t, err)
+		last, err := versionRepo.GetByVersion(context.Background(), tt.recipeId, versions[len(versions)-1].Version)
+		require.Nil(t, err)
+		require.Equal(t, []*rDomain.IngredientLinkSnapshot{tt.expected}, last.Snapshot.Ingredients)
+	}
+
+	_, err = repo.GetById(context.Background(), duplicateId)
+	require.NotNil(t, err)
+}
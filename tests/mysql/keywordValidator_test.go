@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_keywordValidatorRepository_GetAll(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	literal := "слово" + uuid.NewString()[:8]
+	literalId, err := repo.Create(context.Background(), &domain.KeyWord{Word: literal})
+	require.Nil(t, err)
+
+	wildcard := "шабл*" + uuid.NewString()[:8]
+	_, err = repo.CreateRule(context.Background(), &rDomain.KeywordRule{
+		Word: wildcard,
+		Kind: rDomain.KeywordKindWildcard,
+	})
+	require.Nil(t, err)
+	regex := `выраж\w+` + uuid.NewString()[:8]
+	_, err = repo.CreateRule(context.Background(), &rDomain.KeywordRule{
+		Word: regex,
+		Kind: rDomain.KeywordKindRegex,
+	})
+	require.Nil(t, err)
+
+	words, err := repo.GetAll(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, literalId, words[literal])
+	require.NotContains(t, words, wildcard)
+	require.NotContains(t, words, regex)
+}
+
+func Test_keywordValidatorRepository_Create(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	word := "слово" + uuid.NewString()[:8]
+
+	tests := []struct {
+		name     string
+		word     *domain.KeyWord
+		wantErr  bool
+		errIs    error
+		expected *rDomain.KeywordRule
+	}{
+		{
+			name:    "пустое слово",
+			word:    &domain.KeyWord{Word: " "},
+			wantErr: true,
+			errIs:   rDomain.ErrEmptyKeyword,
+		}, // пустое слово
+		{
+			name:    "слишком длинное слово",
+			word:    &domain.KeyWord{Word: strings.Repeat("я", rDomain.MaxKeywordLength+1)},
+			wantErr: true,
+			errIs:   rDomain.ErrKeywordTooLong,
+		}, // слишком длинное слово
+		{
+			name: "успешное создание",
+			word: &domain.KeyWord{Word: " " + word + " "},
+			expected: &rDomain.KeywordRule{
+				Word:     word,
+				Category: rDomain.KeywordCategoryProfanity,
+				Severity: rDomain.KeywordSeverityReject,
+				Kind:     rDomain.KeywordKindLiteral,
+			},
+		}, // успешное создание
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := repo.Create(context.Background(), tt.word)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				var rule rDomain.KeywordRule
+				err = testDbInstance.First(&rule, id).Error
+				require.Nil(t, err)
+				tt.expected.ID = id
+				require.Equal(t, tt.expected, &rule)
+			}
+		})
+	}
+}
+
+func Test_keywordValidatorRepository_Update(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	regexId, err := repo.CreateRule(context.Background(), &rDomain.KeywordRule{
+		Word:     `рег\w+` + uuid.NewString()[:8],
+		Category: rDomain.KeywordCategorySpam,
+		Kind:     rDomain.KeywordKindRegex,
+	})
+	require.Nil(t, err)
+	newId := uuid.New()
+
+	tests := []struct {
+		name     string
+		word     *domain.KeyWord
+		wantErr  bool
+		errIs    error
+		expected *rDomain.KeywordRule
+	}{
+		{
+			name:    "некорректное выражение",
+			word:    &domain.KeyWord{ID: regexId, Word: "("},
+			wantErr: true,
+			errIs:   rDomain.ErrInvalidKeywordPattern,
+		}, // некорректное выражение
+		{
+			name:    "пустое слово",
+			word:    &domain.KeyWord{ID: regexId, Word: " "},
+			wantErr: true,
+			errIs:   rDomain.ErrEmptyKeyword,
+		}, // пустое слово
+		{
+			name: "успешное обновление",
+			word: &domain.KeyWord{ID: regexId, Word: `спам\d+`},
+			expected: &rDomain.KeywordRule{
+				ID:       regexId,
+				Word:     `спам\d+`,
+				Category: rDomain.KeywordCategorySpam,
+				Severity: rDomain.KeywordSeverityReject,
+				Kind:     rDomain.KeywordKindRegex,
+			},
+		}, // успешное обновление
+		{
+			name: "несуществующее слово",
+			word: &domain.KeyWord{ID: newId, Word: "новое" + newId.String()[:8]},
+			expected: &rDomain.KeywordRule{
+				ID:       newId,
+				Word:     "новое" + newId.String()[:8],
+				Category: rDomain.KeywordCategoryProfanity,
+				Severity: rDomain.KeywordSeverityReject,
+				Kind:     rDomain.KeywordKindLiteral,
+			},
+		}, // несуществующее слово
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Update(context.Background(), tt.word)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				var rule rDomain.KeywordRule
+				err = testDbInstance.First(&rule, tt.word.ID).Error
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, &rule)
+			}
+		})
+	}
+
+	matches, err := repo.Match(context.Background(), "спам42")
+	require.Nil(t, err)
+	require.NotEqual(t, 0, len(matches))
+}
+
+func Test_keywordValidatorRepository_ImportExport(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	suffix := uuid.NewString()[:8]
+	input := "# comment\n" +
+		"реклама" + suffix + ",advertising,moderate\n" +
+		"\n" +
+		"  спам" + suffix + " , SPAM , 1\n" +
+		"оскорб*" + suffix + ",,,wildcard\n" +
+		"РЕКЛАМА" + suffix + ",spam\n" +
+		`"ссылк\w+` + suffix + `",spam,reject,regex` + "\n"
+
+	report, err := repo.Import(context.Background(), strings.NewReader(input))
+	require.Nil(t, err)
+	require.Equal(t, &rDomain.KeywordImportReport{Added: 4, Skipped: 1}, report)
+
+	var exported strings.Builder
+	err = repo.Export(context.Background(), &exported)
+	require.Nil(t, err)
+	records, err := csv.NewReader(strings.NewReader(exported.String())).ReadAll()
+	require.Nil(t, err)
+	imported := make([][]string, 0)
+	for _, record := range records {
+		if strings.HasSuffix(record[0], suffix) {
+			imported = append(imported, record)
+		}
+	}
+	require.Equal(t, [][]string{
+		{"реклама" + suffix, "advertising", "moderate"},
+		{"оскорб*" + suffix, "profanity", "reject", "wildcard"},
+		{"спам" + suffix, "spam", "mask"},
+		{`ссылк\w+` + suffix, "spam", "reject", "regex"},
+	}, imported)
+
+	report, err = repo.Import(context.Background(), strings.NewReader(exported.String()))
+	require.Nil(t, err)
+	require.Equal(t, &rDomain.KeywordImportReport{Added: 0, Skipped: len(records)}, report)
+}
+
+func Test_keywordValidatorRepository_ImportDuplicates(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	stored := "хранимое" + uuid.NewString()[:8]
+	_, err := repo.Create(context.Background(), &domain.KeyWord{Word: stored})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected *rDomain.KeywordImportReport
+	}{
+		{
+			name:     "слово уже сохранено",
+			input:    strings.ToUpper(stored) + ",spam\n",
+			expected: &rDomain.KeywordImportReport{Added: 0, Skipped: 1},
+		}, // слово уже сохранено
+		{
+			name:     "повтор в файле",
+			input:    "повтор" + stored + "\nПОВТОР" + stored + ",spam\n",
+			expected: &rDomain.KeywordImportReport{Added: 1, Skipped: 1},
+		}, // повтор в файле
+		{
+			name:     "повторный импорт",
+			input:    "повтор" + stored + "\n",
+			expected: &rDomain.KeywordImportReport{Added: 0, Skipped: 1},
+		}, // повторный импорт
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := repo.Import(context.Background(), strings.NewReader(tt.input))
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, report)
+		})
+	}
+
+	var count int64
+	err = testDbInstance.Model(&rDomain.KeywordRule{}).
+		Where("word in ?", []string{stored, "повтор" + stored}).
+		Count(&count).Error
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func Test_keywordValidatorRepository_ImportInvalid(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+
+	tests := []struct {
+		name  string
+		row   string
+		errIs error
+	}{
+		{
+			name: "лишние поля",
+			row:  "слово,spam,reject,literal,extra",
+		}, // лишние поля
+		{
+			name:  "пустое слово с категорией",
+			row:   ",spam",
+			errIs: rDomain.ErrEmptyKeyword,
+		}, // пустое слово с категорией
+		{
+			name:  "неизвестная категория",
+			row:   "слово,food",
+			errIs: rDomain.ErrUnknownKeywordCategory,
+		}, // неизвестная категория
+		{
+			name:  "неизвестная строгость",
+			row:   "слово,spam,strict",
+			errIs: rDomain.ErrUnknownKeywordSeverity,
+		}, // неизвестная строгость
+		{
+			name:  "неизвестный вид",
+			row:   "слово,spam,reject,3",
+			errIs: rDomain.ErrUnknownKeywordKind,
+		}, // неизвестный вид
+		{
+			name:  "некорректное выражение",
+			row:   "(,spam,reject,regex",
+			errIs: rDomain.ErrInvalidKeywordPattern,
+		}, // некорректное выражение
+		{
+			name:  "незакрытая кавычка",
+			row:   `"слово,spam`,
+			errIs: csv.ErrQuote,
+		}, // незакрытая кавычка
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := "верное" + uuid.NewString()[:8]
+			_, err := repo.Import(context.Background(), strings.NewReader(valid+"\n"+tt.row+"\n"))
+
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), "line 2")
+			if tt.errIs != nil {
+				require.True(t, errors.Is(err, tt.errIs))
+			}
+			var count int64
+			err = testDbInstance.Model(&rDomain.KeywordRule{}).
+				Where("word = ?", valid).
+				Count(&count).Error
+			require.Nil(t, err)
+			require.Equal(t, int64(0), count)
+		})
+	}
+}
+
+func Test_keywordValidatorRepository_MatchDuringCreate(t *testing.T) {
+	repo := mysql.NewKeywordValidatorRepository(testDbInstance)
+	banned := "запрет" + uuid.NewString()[:8]
+	_, err := repo.Create(context.Background(), &domain.KeyWord{Word: banned})
+	require.Nil(t, err)
+
+	const writes = 20
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < writes; i++ {
+			_, err := repo.Create(context.Background(), &domain.KeyWord{Word: "другое" + uuid.NewString()[:8]})
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// every match runs while the matcher may be invalidated by the writer,
+	// the banned word must be found each time
+	for i := 0; i < writes*5; i++ {
+		matches, err := repo.Match(context.Background(), "текст "+banned)
+		require.Nil(t, err)
+		words := make([]string, 0, len(matches))
+		for _, match := range matches {
+			words = append(words, match.Word)
+		}
+		require.Contains(t, words, banned)
+	}
+	require.Nil(t, <-done)
+}
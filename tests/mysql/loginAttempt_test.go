@@ -0,0 +1,275 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_loginAttemptRepository_CountFailures(t *testing.T) {
+	repo := mysql.NewLoginAttemptRepository(testDbInstance)
+	userId := newTestUser(t)
+	ip := "10.0." + uuid.NewString()[:3]
+	for _, attempt := range []struct {
+		success bool
+		age     time.Duration
+	}{
+		{false, time.Minute},
+		{false, 2 * time.Minute},
+		{true, 3 * time.Minute},
+		{false, 2 * time.Hour},
+	} {
+		err := repo.Record(context.Background(), &rDomain.LoginAttempt{
+			UserID:    uuid.NullUUID{UUID: userId, Valid: true},
+			Login:     "login",
+			IP:        ip,
+			Success:   attempt.success,
+			CreatedAt: time.Now().Add(-attempt.age),
+		})
+		require.Nil(t, err)
+	}
+	err := repo.Record(context.Background(), &rDomain.LoginAttempt{
+		Login: "unknown",
+		IP:    ip,
+	})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		window   time.Duration
+		userFail int
+		ipFail   int
+	}{
+		{
+			name:     "короткое окно",
+			window:   90 * time.Second,
+			userFail: 1,
+			ipFail:   2,
+		}, // короткое окно
+		{
+			name:     "окно в час",
+			window:   time.Hour,
+			userFail: 2,
+			ipFail:   3,
+		}, // окно в час
+		{
+			name:     "окно в сутки",
+			window:   24 * time.Hour,
+			userFail: 3,
+			ipFail:   4,
+		}, // окно в сутки
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.CountUserFailures(context.Background(), userId, tt.window)
+			require.Nil(t, err)
+			require.Equal(t, tt.userFail, count)
+
+			count, err = repo.CountIPFailures(context.Background(), ip, tt.window)
+			require.Nil(t, err)
+			require.Equal(t, tt.ipFail, count)
+		})
+	}
+}
+
+func Test_loginAttemptRepository_Record(t *testing.T) {
+	repo := mysql.NewLoginAttemptRepository(testDbInstance)
+
+	tests := []struct {
+		name     string
+		login    string
+		expected string
+	}{
+		{
+			name:     "обычный логин",
+			login:    "login",
+			expected: "login",
+		}, // обычный логин
+		{
+			name:     "слишком длинный логин",
+			login:    strings.Repeat("a", 1000),
+			expected: strings.Repeat("a", rDomain.MaxAttemptLoginLength),
+		}, // слишком длинный логин
+		{
+			name:     "длинный логин кириллицей",
+			login:    strings.Repeat("я", rDomain.MaxAttemptLoginLength+1),
+			expected: strings.Repeat("я", rDomain.MaxAttemptLoginLength),
+		}, // длинный логин кириллицей
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := "10.1." + uuid.NewString()[:3]
+			err := repo.Record(context.Background(), &rDomain.LoginAttempt{
+				Login: tt.login,
+				IP:    ip,
+			})
+			require.Nil(t, err)
+
+			count, err := repo.CountIPFailures(context.Background(), ip, time.Minute)
+			require.Nil(t, err)
+			require.Equal(t, 1, count)
+
+			var logins []string
+			err = testDbInstance.Model(&rDomain.LoginAttempt{}).
+				Where("ip = ?", ip).
+				Pluck("login", &logins).Error
+			require.Nil(t, err)
+			require.Equal(t, []string{tt.expected}, logins)
+		})
+	}
+}
+
+func Test_loginAttemptRepository_RecordRetry(t *testing.T) {
+	repo := mysql.NewLoginAttemptRepository(testDbInstance)
+	attemptId := uuid.New()
+	ip := "10.1." + uuid.NewString()[:3]
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "запись с выбранным id",
+			wantErr: false,
+		}, // запись с выбранным id
+		{
+			name:    "повтор записи",
+			wantErr: true,
+			errIs:   rDomain.ErrAlreadyExists,
+		}, // повтор записи
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Record(context.Background(), &rDomain.LoginAttempt{
+				ID:    attemptId,
+				Login: "login",
+				IP:    ip,
+			})
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+			}
+			count, err := repo.CountIPFailures(context.Background(), ip, time.Minute)
+			require.Nil(t, err)
+			require.Equal(t, 1, count)
+		})
+	}
+}
+
+func Test_loginAttemptRepository_Lock(t *testing.T) {
+	repo := mysql.NewLoginAttemptRepository(testDbInstance)
+	userId := newTestUser(t)
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		userId   uuid.UUID
+		action   func(userId uuid.UUID) error
+		wantErr  bool
+		errIs    error
+		expected *rDomain.Lockout
+	}{
+		{
+			name:   "блокировка",
+			userId: userId,
+			action: func(userId uuid.UUID) error {
+				return repo.Lock(context.Background(), userId, until)
+			},
+			expected: &rDomain.Lockout{Locked: true, LockedUntil: until},
+		}, // блокировка
+		{
+			name:   "разблокировка",
+			userId: userId,
+			action: func(userId uuid.UUID) error {
+				return repo.Unlock(context.Background(), userId)
+			},
+			expected: &rDomain.Lockout{},
+		}, // разблокировка
+		{
+			name:   "истекшая блокировка",
+			userId: userId,
+			action: func(userId uuid.UUID) error {
+				return repo.Lock(context.Background(), userId, time.Now().Add(-time.Minute))
+			},
+			expected: &rDomain.Lockout{},
+		}, // истекшая блокировка
+		{
+			name:   "повторная разблокировка",
+			userId: userId,
+			action: func(userId uuid.UUID) error {
+				return repo.Unlock(context.Background(), userId)
+			},
+			expected: &rDomain.Lockout{},
+		}, // повторная разблокировка
+		{
+			name:   "несуществующий пользователь",
+			userId: uuid.New(),
+			action: func(userId uuid.UUID) error {
+				return repo.Lock(context.Background(), userId, until)
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий пользователь
+		{
+			name:   "разблокировка несуществующего пользователя",
+			userId: uuid.New(),
+			action: func(userId uuid.UUID) error {
+				return repo.Unlock(context.Background(), userId)
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // разблокировка несуществующего пользователя
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.action(tt.userId)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				_, err = repo.GetLockout(context.Background(), tt.userId)
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				lockout, err := repo.GetLockout(context.Background(), tt.userId)
+				require.Nil(t, err)
+				require.Equal(t, tt.expected.Locked, lockout.Locked)
+				require.True(t, tt.expected.LockedUntil.Equal(lockout.LockedUntil))
+			}
+		})
+	}
+}
+
+func Test_loginAttemptRepository_Cleanup(t *testing.T) {
+	repo := mysql.NewLoginAttemptRepository(testDbInstance)
+	ip := "10.1." + uuid.NewString()[:3]
+	for _, age := range []time.Duration{time.Minute, 48 * time.Hour, 72 * time.Hour} {
+		err := repo.Record(context.Background(), &rDomain.LoginAttempt{
+			Login:     "login",
+			IP:        ip,
+			CreatedAt: time.Now().Add(-age),
+		})
+		require.Nil(t, err)
+	}
+
+	count, err := repo.Cleanup(context.Background(), time.Now().Add(-24*time.Hour))
+	require.Nil(t, err)
+	require.GreaterOrEqual(t, count, 2)
+
+	failures, err := repo.CountIPFailures(context.Background(), ip, 7*24*time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 1, failures)
+
+	count, err = repo.Cleanup(context.Background(), time.Now().Add(-24*time.Hour))
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+}
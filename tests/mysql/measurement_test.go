@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func Test_measurementRepository_DeleteWithOptions(t *testing.T) {
+	repo := mysql.NewMeasrementRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+
+	newMeasurement := func(name string, grams int) uuid.UUID {
+		id, err := repo.Create(context.Background(), &domain.Measurement{Name: name, Grams: grams})
+		require.Nil(t, err)
+		return id
+	}
+	usedId := newMeasurement("горсть", 10)
+	replacementId := newMeasurement("пригоршня", 5)
+	largeId := newMeasurement("мешок", 1000)
+	unusedId := newMeasurement("щепотка", 10)
+	linkId, err := ingredientRepo.Link(context.Background(), recipeId, idByName(t, "ingredient", "морковь"))
+	require.Nil(t, err)
+	err = repo.UpdateLink(context.Background(), linkId, usedId, 3)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		opts    *rDomain.DeleteOptions
+		wantErr bool
+		errIs   error
+		inUse   bool
+		deleted bool
+	}{
+		{
+			name:    "используемая единица",
+			id:      usedId,
+			wantErr: true,
+			inUse:   true,
+		}, // используемая единица
+		{
+			name:    "замена на саму себя",
+			id:      usedId,
+			opts:    &rDomain.DeleteOptions{ReplacementId: usedId},
+			wantErr: true,
+			errIs:   rDomain.ErrReplaceWithItself,
+		}, // замена на саму себя
+		{
+			name:    "несуществующая замена",
+			id:      usedId,
+			opts:    &rDomain.DeleteOptions{ReplacementId: uuid.New()},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующая замена
+		{
+			name:    "замена с потерей количества",
+			id:      usedId,
+			opts:    &rDomain.DeleteOptions{ReplacementId: largeId},
+			wantErr: true,
+			errIs:   rDomain.ErrIncompatibleMeasurements,
+		}, // замена с потерей количества
+		{
+			name:    "с заменой",
+			id:      usedId,
+			opts:    &rDomain.DeleteOptions{ReplacementId: replacementId},
+			deleted: true,
+		}, // с заменой
+		{
+			name:    "неиспользуемая единица",
+			id:      unusedId,
+			deleted: true,
+		}, // неиспользуемая единица
+		{
+			name: "повторное удаление",
+			id:   unusedId,
+		}, // повторное удаление
+		{
+			name: "несуществующая единица",
+			id:   uuid.New(),
+		}, // несуществующая единица
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.DeleteWithOptions(context.Background(), tt.id, tt.opts)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				if tt.errIs != nil {
+					require.True(t, errors.Is(err, tt.errIs))
+				}
+				var inUse *rDomain.ErrInUse
+				require.Equal(t, tt.inUse, errors.As(err, &inUse))
+				if tt.inUse {
+					require.Equal(t, 1, len(inUse.Usages))
+					require.Equal(t, recipeId, inUse.Usages[0].ID)
+				}
+				return
+			}
+			require.Nil(t, err)
+			if tt.deleted {
+				_, err = repo.GetById(context.Background(), tt.id)
+				require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+			}
+		})
+	}
+
+	measurement, amount, err := repo.GetByRecipeId(context.Background(), idByName(t, "ingredient", "морковь"), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, replacementId, measurement.ID)
+	require.Equal(t, 6, amount)
+
+	versionRepo := mysql.NewRecipeVersionRepository(testDbInstance)
+	versions, err := versionRepo.GetAll(context.Background(), recipeId)
+	require.Nil(t, err)
+	last, err := versionRepo.GetByVersion(context.Background(), recipeId, versions[len(versions)-1].Version)
+	require.Nil(t, err)
+	require.Equal(t, []*rDomain.IngredientLinkSnapshot{{
+		ID:           linkId,
+		IngredientId: idByName(t, "ingredient", "морковь"),
+		Measurement:  replacementId,
+		Amount:       6,
+	}}, last.Snapshot.Ingredients)
+}
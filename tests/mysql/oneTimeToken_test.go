@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func Test_oneTimeTokenRepository_Create(t *testing.T) {
+	repo := mysql.NewOneTimeTokenRepository(testDbInstance)
+	userId := newTestUser(t)
+	tokenId := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   *rDomain.OneTimeToken
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name: "создание с выбранным id",
+			token: &rDomain.OneTimeToken{
+				ID:        tokenId,
+				UserID:    userId,
+				Purpose:   rDomain.TokenPurposeEmailVerification,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		}, // создание с выбранным id
+		{
+			name: "повтор создания",
+			token: &rDomain.OneTimeToken{
+				ID:        tokenId,
+				UserID:    userId,
+				Purpose:   rDomain.TokenPurposeEmailVerification,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrAlreadyExists,
+		}, // повтор создания
+		{
+			name: "несуществующий пользователь",
+			token: &rDomain.OneTimeToken{
+				UserID:    uuid.New(),
+				Purpose:   rDomain.TokenPurposeEmailVerification,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий пользователь
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := uuid.NewString()
+			_, err := repo.Create(context.Background(), tt.token, plaintext)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				_, err = repo.Consume(context.Background(), plaintext, tt.token.Purpose)
+				require.True(t, errors.Is(err, rDomain.ErrTokenNotFound))
+			} else {
+				require.Nil(t, err)
+				token, err := repo.Consume(context.Background(), plaintext, tt.token.Purpose)
+				require.Nil(t, err)
+				require.Equal(t, tokenId, token.ID)
+			}
+		})
+	}
+}
+
+func Test_oneTimeTokenRepository_Consume(t *testing.T) {
+	repo := mysql.NewOneTimeTokenRepository(testDbInstance)
+	userId := newTestUser(t)
+	resetToken := newTestToken(t, userId, rDomain.TokenPurposePasswordReset, time.Now().Add(time.Hour))
+	expiredToken := newTestToken(t, userId, rDomain.TokenPurposePasswordReset, time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name      string
+		plaintext string
+		purpose   string
+		wantErr   bool
+		errIs     error
+	}{
+		{
+			name:      "другое назначение",
+			plaintext: resetToken,
+			purpose:   rDomain.TokenPurposeEmailVerification,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenNotFound,
+		}, // другое назначение
+		{
+			name:      "успешное использование",
+			plaintext: resetToken,
+			purpose:   rDomain.TokenPurposePasswordReset,
+		}, // успешное использование
+		{
+			name:      "повторное использование",
+			plaintext: resetToken,
+			purpose:   rDomain.TokenPurposePasswordReset,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenConsumed,
+		}, // повторное использование
+		{
+			name:      "истекший токен",
+			plaintext: expiredToken,
+			purpose:   rDomain.TokenPurposePasswordReset,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenExpired,
+		}, // истекший токен
+		{
+			name:      "неизвестный токен",
+			plaintext: uuid.NewString(),
+			purpose:   rDomain.TokenPurposePasswordReset,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenNotFound,
+		}, // неизвестный токен
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := repo.Consume(context.Background(), tt.plaintext, tt.purpose)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, userId, token.UserID)
+				require.NotNil(t, token.ConsumedAt)
+			}
+		})
+	}
+}
+
+func Test_oneTimeTokenRepository_VerifyEmail(t *testing.T) {
+	repo := mysql.NewOneTimeTokenRepository(testDbInstance)
+	userRepo := mysql.NewUserRepository(testDbInstance)
+	userId := newTestUser(t)
+	otherUserId := newTestUser(t)
+	verificationToken := newTestToken(t, userId, rDomain.TokenPurposeEmailVerification, time.Now().Add(time.Hour))
+	resetToken := newTestToken(t, otherUserId, rDomain.TokenPurposePasswordReset, time.Now().Add(time.Hour))
+	oldEmailToken := newTestToken(t, otherUserId, rDomain.TokenPurposeEmailVerification, time.Now().Add(time.Hour))
+	expiredToken := newTestToken(t, otherUserId, rDomain.TokenPurposeEmailVerification, time.Now().Add(-time.Minute))
+	changeEmail := func(userId uuid.UUID) {
+		user, err := userRepo.GetById(context.Background(), userId)
+		require.Nil(t, err)
+		user.Email.Address = "changed" + uuid.NewString()[:8] + "@mail.ru"
+		require.Nil(t, userRepo.Update(context.Background(), user))
+	}
+
+	tests := []struct {
+		name       string
+		beforeTest func()
+		plaintext  string
+		wantErr    bool
+		errIs      error
+		userId     uuid.UUID
+		verified   bool
+	}{
+		{
+			name:      "токен сброса пароля",
+			plaintext: resetToken,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenNotFound,
+			userId:    otherUserId,
+		}, // токен сброса пароля
+		{
+			name:      "успешное подтверждение",
+			plaintext: verificationToken,
+			userId:    userId,
+			verified:  true,
+		}, // успешное подтверждение
+		{
+			name:      "повторное подтверждение",
+			plaintext: verificationToken,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenConsumed,
+			userId:    userId,
+			verified:  true,
+		}, // повторное подтверждение
+		{
+			name:       "смена подтвержденной почты",
+			beforeTest: func() { changeEmail(userId) },
+			plaintext:  verificationToken,
+			wantErr:    true,
+			errIs:      rDomain.ErrTokenConsumed,
+			userId:     userId,
+			verified:   false,
+		}, // смена подтвержденной почты
+		{
+			name:       "токен выдан для прежней почты",
+			beforeTest: func() { changeEmail(otherUserId) },
+			plaintext:  oldEmailToken,
+			wantErr:    true,
+			errIs:      rDomain.ErrTokenEmail,
+			userId:     otherUserId,
+			verified:   false,
+		}, // токен выдан для прежней почты
+		{
+			name:      "неизвестный токен",
+			plaintext: uuid.NewString(),
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenNotFound,
+			userId:    otherUserId,
+			verified:  false,
+		}, // неизвестный токен
+		{
+			name:      "истекший токен",
+			plaintext: expiredToken,
+			wantErr:   true,
+			errIs:     rDomain.ErrTokenExpired,
+			userId:    otherUserId,
+			verified:  false,
+		}, // истекший токен
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.beforeTest != nil {
+				tt.beforeTest()
+			}
+			id, err := repo.VerifyEmail(context.Background(), tt.plaintext)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.userId, id)
+			}
+			verified, err := userRepo.IsEmailVerified(context.Background(), tt.userId)
+			require.Nil(t, err)
+			require.Equal(t, tt.verified, verified)
+		})
+	}
+}
+
+func Test_oneTimeTokenRepository_PurgeExpired(t *testing.T) {
+	repo := mysql.NewOneTimeTokenRepository(testDbInstance)
+	userId := newTestUser(t)
+	expiredToken := newTestToken(t, userId, rDomain.TokenPurposePasswordReset, time.Now().Add(-time.Minute))
+	activeToken := newTestToken(t, userId, rDomain.TokenPurposePasswordReset, time.Now().Add(time.Hour))
+
+	count, err := repo.PurgeExpired(context.Background())
+	require.Nil(t, err)
+	require.GreaterOrEqual(t, count, 1)
+
+	_, err = repo.Consume(context.Background(), expiredToken, rDomain.TokenPurposePasswordReset)
+	require.True(t, errors.Is(err, rDomain.ErrTokenNotFound))
+	_, err = repo.Consume(context.Background(), activeToken, rDomain.TokenPurposePasswordReset)
+	require.Nil(t, err)
+
+	count, err = repo.PurgeExpired(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+}
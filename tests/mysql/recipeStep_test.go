@@ -3,11 +3,15 @@ package tests
 import (
 	"context"
 	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_recipeStepRepository_Create(t *testing.T) {
@@ -28,6 +32,16 @@ func Test_recipeStepRepository_Create(t *testing.T) {
 			},
 			wantErr: false,
 		}, // успешное создание
+		{
+			name: "создание с заданным ID",
+			recipeStep: &domain.RecipeStep{
+				ID:          uuid.UUID{3, 3},
+				RecipeID:    uuid.UUID{3},
+				Name:        "second",
+				Description: "description",
+			},
+			wantErr: false,
+		}, // создание с заданным ID
 		{
 			name: "несуществующий рецепт",
 			recipeStep: &domain.RecipeStep{
@@ -41,12 +55,16 @@ func Test_recipeStepRepository_Create(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := repo.Create(context.Background(), tt.recipeStep)
+			id, err := repo.Create(context.Background(), tt.recipeStep)
 
 			if tt.wantErr {
 				require.Equal(t, tt.errStr.Error(), err.Error())
 			} else {
 				require.Nil(t, err)
+				require.NotEqual(t, uuid.Nil, id)
+				if tt.recipeStep.ID != uuid.Nil {
+					require.Equal(t, tt.recipeStep.ID, id)
+				}
 			}
 		})
 	}
@@ -549,3 +567,464 @@ func Test_recipeStepRepository_DeleteById(t *testing.T) {
 		})
 	}
 }
+
+func Test_recipeStepRepository_Concurrent(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	saladId, err := mysql.NewSaladRepository(testDbInstance).
+		Create(context.Background(), &domain.Salad{Name: "concurrent"})
+	require.Nil(t, err)
+	recipeId, err := mysql.NewRecipeRepository(testDbInstance).
+		Create(context.Background(), &domain.Recipe{
+			SaladID:          saladId,
+			Status:           domain.EditingSaladStatus,
+			NumberOfServings: 1,
+			TimeToCook:       1,
+		})
+	require.Nil(t, err)
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Create(context.Background(), &domain.RecipeStep{
+				RecipeID:    recipeId,
+				Name:        "step",
+				Description: "description",
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	steps, err := repo.GetAllByRecipeID(context.Background(), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, workers, len(steps))
+
+	errs = make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				errs <- repo.DeleteById(context.Background(), steps[i].ID)
+			} else {
+				errs <- repo.Update(context.Background(), &domain.RecipeStep{
+					ID:          steps[i].ID,
+					RecipeID:    recipeId,
+					Name:        "moved",
+					Description: "moved",
+					StepNum:     1,
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	steps, err = repo.GetAllByRecipeID(context.Background(), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, workers/2, len(steps))
+	for i, step := range steps {
+		require.Equal(t, i+1, step.StepNum)
+	}
+}
+
+func Test_recipeStepRepository_DeleteAllConcurrent(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	newTestSteps(t, recipeId, "first", "second", "third")
+
+	const workers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%5 == 0 {
+				errs <- repo.DeleteAllByRecipeID(context.Background(), recipeId)
+				return
+			}
+			_, err := repo.InsertAt(context.Background(), &domain.RecipeStep{
+				RecipeID:    recipeId,
+				Name:        "inserted",
+				Description: "description",
+			}, 1)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	steps, err := repo.GetAllByRecipeID(context.Background(), recipeId)
+	require.Nil(t, err)
+	for i, step := range steps {
+		require.Equal(t, i+1, step.StepNum)
+	}
+}
+
+func stepNames(t *testing.T, recipeId uuid.UUID) []string {
+	steps, err := mysql.NewRecipeStepRepository(testDbInstance).GetAllByRecipeID(context.Background(), recipeId)
+	require.Nil(t, err)
+	names := make([]string, 0, len(steps))
+	for i, step := range steps {
+		require.Equal(t, i+1, step.StepNum)
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func Test_recipeStepRepository_Reorder(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	_, otherRecipeId := newTestRecipe(t, newTestUser(t))
+	ids := newTestSteps(t, recipeId, "a", "b", "c")
+	otherIds := newTestSteps(t, otherRecipeId, "d")
+
+	tests := []struct {
+		name     string
+		order    []uuid.UUID
+		wantErr  bool
+		errIs    error
+		expected []string
+	}{
+		{
+			name:     "не все шаги",
+			order:    []uuid.UUID{ids[1], ids[0]},
+			wantErr:  true,
+			errIs:    rDomain.ErrInvalidStepOrder,
+			expected: []string{"a", "b", "c"},
+		}, // не все шаги
+		{
+			name:     "повтор шага",
+			order:    []uuid.UUID{ids[1], ids[1], ids[0]},
+			wantErr:  true,
+			errIs:    rDomain.ErrInvalidStepOrder,
+			expected: []string{"a", "b", "c"},
+		}, // повтор шага
+		{
+			name:     "шаг другого рецепта",
+			order:    []uuid.UUID{ids[1], otherIds[0], ids[0]},
+			wantErr:  true,
+			errIs:    rDomain.ErrInvalidStepOrder,
+			expected: []string{"a", "b", "c"},
+		}, // шаг другого рецепта
+		{
+			name:     "успешное изменение порядка",
+			order:    []uuid.UUID{ids[2], ids[0], ids[1]},
+			expected: []string{"c", "a", "b"},
+		}, // успешное изменение порядка
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Reorder(context.Background(), recipeId, tt.order)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expected, stepNames(t, recipeId))
+		})
+	}
+	require.Equal(t, []string{"d"}, stepNames(t, otherRecipeId))
+}
+
+func Test_recipeStepRepository_InsertAt(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	newTestSteps(t, recipeId, "a", "b")
+
+	tests := []struct {
+		name     string
+		step     string
+		position int
+		wantErr  bool
+		errIs    error
+		expected []string
+	}{
+		{
+			name:     "нулевая позиция",
+			step:     "zero",
+			position: 0,
+			wantErr:  true,
+			errIs:    rDomain.ErrStepOutOfRange,
+			expected: []string{"a", "b"},
+		}, // нулевая позиция
+		{
+			name:     "позиция после конца",
+			step:     "far",
+			position: 4,
+			wantErr:  true,
+			errIs:    rDomain.ErrStepOutOfRange,
+			expected: []string{"a", "b"},
+		}, // позиция после конца
+		{
+			name:     "вставка в начало",
+			step:     "first",
+			position: 1,
+			expected: []string{"first", "a", "b"},
+		}, // вставка в начало
+		{
+			name:     "вставка в конец",
+			step:     "last",
+			position: 4,
+			expected: []string{"first", "a", "b", "last"},
+		}, // вставка в конец
+		{
+			name:     "вставка в середину",
+			step:     "middle",
+			position: 3,
+			expected: []string{"first", "a", "middle", "b", "last"},
+		}, // вставка в середину
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := repo.InsertAt(context.Background(), &domain.RecipeStep{
+				RecipeID:    recipeId,
+				Name:        tt.step,
+				Description: "description",
+			}, tt.position)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				step, err := repo.GetById(context.Background(), id)
+				require.Nil(t, err)
+				require.Equal(t, tt.position, step.StepNum)
+			}
+			require.Equal(t, tt.expected, stepNames(t, recipeId))
+		})
+	}
+}
+
+func Test_recipeStepRepository_UpdateDetails(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	stepIds := newTestSteps(t, recipeId, "a")
+
+	tests := []struct {
+		name     string
+		stepId   uuid.UUID
+		details  *rDomain.RecipeStepDetails
+		wantErr  bool
+		errIs    error
+		expected rDomain.RecipeStepDetails
+	}{
+		{
+			name:   "успешное обновление",
+			stepId: stepIds[0],
+			details: &rDomain.RecipeStepDetails{
+				Section:    "  соус ",
+				Duration:   90 * time.Second,
+				TimerLabel: "варить",
+			},
+			expected: rDomain.RecipeStepDetails{
+				Section:    "соус",
+				Duration:   90 * time.Second,
+				TimerLabel: "варить",
+			},
+		}, // успешное обновление
+		{
+			name:   "несуществующий шаг",
+			stepId: uuid.New(),
+			details: &rDomain.RecipeStepDetails{
+				Section: "соус",
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий шаг
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.UpdateDetails(context.Background(), tt.stepId, tt.details)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				sections, err := repo.GetSections(context.Background(), recipeId)
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, sections[0].Steps[0].Details)
+			}
+		})
+	}
+}
+
+func Test_recipeStepRepository_LinkIngredient(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	_, otherRecipeId := newTestRecipe(t, newTestUser(t))
+	stepIds := newTestSteps(t, recipeId, "a")
+	linkId, err := ingredientRepo.Link(context.Background(), recipeId, idByName(t, "ingredient", "морковь"))
+	require.Nil(t, err)
+	foreignLinkId, err := ingredientRepo.Link(context.Background(), otherRecipeId, idByName(t, "ingredient", "морковь"))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		stepId   uuid.UUID
+		linkId   uuid.UUID
+		wantErr  bool
+		errIs    error
+		expected []uuid.UUID
+	}{
+		{
+			name:     "успешная привязка",
+			stepId:   stepIds[0],
+			linkId:   linkId,
+			expected: []uuid.UUID{linkId},
+		}, // успешная привязка
+		{
+			name:     "ингредиент другого рецепта",
+			stepId:   stepIds[0],
+			linkId:   foreignLinkId,
+			wantErr:  true,
+			errIs:    rDomain.ErrForeignRecipeLink,
+			expected: []uuid.UUID{linkId},
+		}, // ингредиент другого рецепта
+		{
+			name:     "несуществующий шаг",
+			stepId:   uuid.New(),
+			linkId:   linkId,
+			wantErr:  true,
+			errIs:    gorm.ErrRecordNotFound,
+			expected: []uuid.UUID{linkId},
+		}, // несуществующий шаг
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.LinkIngredient(context.Background(), tt.stepId, tt.linkId)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expected, stepLinkIds(t, recipeId, stepIds[0]))
+		})
+	}
+}
+
+func Test_recipeStepRepository_UnlinkIngredient(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	stepIds := newTestSteps(t, recipeId, "a", "b")
+	linkId, err := ingredientRepo.Link(context.Background(), recipeId, idByName(t, "ingredient", "морковь"))
+	require.Nil(t, err)
+	for _, stepId := range stepIds {
+		err = repo.LinkIngredient(context.Background(), stepId, linkId)
+		require.Nil(t, err)
+	}
+
+	tests := []struct {
+		name     string
+		stepId   uuid.UUID
+		linkId   uuid.UUID
+		expected [][]uuid.UUID
+	}{
+		{
+			name:     "успешная отвязка",
+			stepId:   stepIds[0],
+			linkId:   linkId,
+			expected: [][]uuid.UUID{{}, {linkId}},
+		}, // успешная отвязка
+		{
+			name:     "повторная отвязка",
+			stepId:   stepIds[0],
+			linkId:   linkId,
+			expected: [][]uuid.UUID{{}, {linkId}},
+		}, // повторная отвязка
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.UnlinkIngredient(context.Background(), tt.stepId, tt.linkId)
+
+			require.Nil(t, err)
+			for i, stepId := range stepIds {
+				require.Equal(t, tt.expected[i], stepLinkIds(t, recipeId, stepId))
+			}
+		})
+	}
+}
+
+func Test_recipeStepRepository_GetSections(t *testing.T) {
+	repo := mysql.NewRecipeStepRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	_, emptyRecipeId := newTestRecipe(t, newTestUser(t))
+	stepIds := newTestSteps(t, recipeId, "a", "b", "c", "d")
+	for i, section := range []string{"тесто", "тесто", "крем", "тесто"} {
+		err := repo.UpdateDetails(context.Background(), stepIds[i], &rDomain.RecipeStepDetails{Section: section})
+		require.Nil(t, err)
+	}
+
+	tests := []struct {
+		name     string
+		recipeId uuid.UUID
+		expected map[string][]string
+		names    []string
+	}{
+		{
+			name:     "шаги по разделам",
+			recipeId: recipeId,
+			names:    []string{"тесто", "крем", "тесто"},
+			expected: map[string][]string{"тесто": {"a", "b", "d"}, "крем": {"c"}},
+		}, // шаги по разделам
+		{
+			name:     "рецепт без шагов",
+			recipeId: emptyRecipeId,
+			names:    []string{},
+			expected: map[string][]string{},
+		}, // рецепт без шагов
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections, err := repo.GetSections(context.Background(), tt.recipeId)
+			require.Nil(t, err)
+
+			names := make([]string, 0)
+			steps := make(map[string][]string)
+			for _, section := range sections {
+				names = append(names, section.Name)
+				for _, step := range section.Steps {
+					steps[section.Name] = append(steps[section.Name], step.Step.Name)
+				}
+			}
+			require.Equal(t, tt.names, names)
+			require.Equal(t, tt.expected, steps)
+		})
+	}
+}
+
+func stepLinkIds(t *testing.T, recipeId uuid.UUID, stepId uuid.UUID) []uuid.UUID {
+	sections, err := mysql.NewRecipeStepRepository(testDbInstance).GetSections(context.Background(), recipeId)
+	require.Nil(t, err)
+	ids := make([]uuid.UUID, 0)
+	for _, section := range sections {
+		for _, step := range section.Steps {
+			if step.Step.ID != stepId {
+				continue
+			}
+			for _, ingredient := range step.Ingredients {
+				ids = append(ids, ingredient.LinkId)
+			}
+		}
+	}
+	return ids
+}
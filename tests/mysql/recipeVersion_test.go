@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_recipeVersionRepository_DiffAndRevert(t *testing.T) {
+	recipeRepo := mysql.NewRecipeRepository(testDbInstance)
+	repo := mysql.NewRecipeVersionRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+
+	recipe, err := recipeRepo.GetById(context.Background(), recipeId)
+	require.Nil(t, err)
+	oldTime := recipe.TimeToCook
+
+	recipe.TimeToCook = oldTime + 10
+	err = recipeRepo.Update(context.Background(), recipe)
+	require.Nil(t, err)
+
+	versions, err := repo.GetAll(context.Background(), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(versions))
+
+	diff, err := repo.Diff(context.Background(), recipeId, 1, 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(diff.Fields))
+	require.Equal(t, "recipe.timeToCook", diff.Fields[0].Field)
+	require.Equal(t, 0, len(diff.AddedSteps)+len(diff.RemovedSteps)+len(diff.ChangedSteps))
+
+	version, err := repo.Revert(context.Background(), recipeId, 1)
+	require.Nil(t, err)
+	require.Equal(t, 3, version)
+
+	recipe, err = recipeRepo.GetById(context.Background(), recipeId)
+	require.Nil(t, err)
+	require.Equal(t, oldTime, recipe.TimeToCook)
+}
+
+func Test_recipeVersionRepository_RevertChanged(t *testing.T) {
+	recipeRepo := mysql.NewRecipeRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+	repo := mysql.NewRecipeVersionRepository(testDbInstance)
+	typeId := idByName(t, "ingredientType", "овощ")
+
+	lastVersion := func(t *testing.T, recipeId uuid.UUID) int {
+		versions, err := repo.GetAll(context.Background(), recipeId)
+		require.Nil(t, err)
+		return versions[len(versions)-1].Version
+	}
+	newIngredient := func(t *testing.T) uuid.UUID {
+		id, err := ingredientRepo.Create(context.Background(), &domain.Ingredient{
+			TypeID:   typeId,
+			Name:     "ингредиент " + uuid.NewString()[:8],
+			Calories: 1,
+		})
+		require.Nil(t, err)
+		return id
+	}
+	linkedIngredients := func(t *testing.T, recipeId uuid.UUID) []uuid.UUID {
+		ingredients, err := ingredientRepo.GetAllByRecipeId(context.Background(), recipeId)
+		require.Nil(t, err)
+		ids := make([]uuid.UUID, 0, len(ingredients))
+		for _, ingredient := range ingredients {
+			ids = append(ids, ingredient.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		// prepare changes a recipe and returns it with the version to revert to
+		prepare func(t *testing.T) (uuid.UUID, int)
+		check   func(t *testing.T, recipeId uuid.UUID)
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name: "ингредиент убран из рецепта",
+			prepare: func(t *testing.T) (uuid.UUID, int) {
+				_, recipeId := newTestRecipe(t, newTestUser(t))
+				ingredientId := newIngredient(t)
+				_, err := ingredientRepo.Link(context.Background(), recipeId, ingredientId)
+				require.Nil(t, err)
+				version := lastVersion(t, recipeId)
+				err = ingredientRepo.Unlink(context.Background(), recipeId, ingredientId)
+				require.Nil(t, err)
+				return recipeId, version
+			},
+			check: func(t *testing.T, recipeId uuid.UUID) {
+				require.Equal(t, 1, len(linkedIngredients(t, recipeId)))
+			},
+			wantErr: false,
+		}, // ингредиент убран из рецепта
+		{
+			name: "ингредиент удален",
+			prepare: func(t *testing.T) (uuid.UUID, int) {
+				_, recipeId := newTestRecipe(t, newTestUser(t))
+				ingredientId := newIngredient(t)
+				_, err := ingredientRepo.Link(context.Background(), recipeId, ingredientId)
+				require.Nil(t, err)
+				version := lastVersion(t, recipeId)
+				err = ingredientRepo.Unlink(context.Background(), recipeId, ingredientId)
+				require.Nil(t, err)
+				err = ingredientRepo.DeleteById(context.Background(), ingredientId)
+				require.Nil(t, err)
+				return recipeId, version
+			},
+			check: func(t *testing.T, recipeId uuid.UUID) {
+				require.Equal(t, 0, len(linkedIngredients(t, recipeId)))
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrVersionReferencesDeleted,
+		}, // ингредиент удален
+		{
+			name: "ингредиент слит с другим",
+			prepare: func(t *testing.T) (uuid.UUID, int) {
+				_, recipeId := newTestRecipe(t, newTestUser(t))
+				keepId, duplicateId := newIngredient(t), newIngredient(t)
+				_, err := ingredientRepo.Link(context.Background(), recipeId, duplicateId)
+				require.Nil(t, err)
+				version := lastVersion(t, recipeId)
+				_, err = ingredientRepo.Merge(context.Background(), keepId, []uuid.UUID{duplicateId})
+				require.Nil(t, err)
+				return recipeId, version
+			},
+			check: func(t *testing.T, recipeId uuid.UUID) {
+				require.Equal(t, 1, len(linkedIngredients(t, recipeId)))
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrVersionReferencesDeleted,
+		}, // ингредиент слит с другим
+		{
+			name: "клонированный рецепт",
+			prepare: func(t *testing.T) (uuid.UUID, int) {
+				saladId, _ := newTestRecipe(t, newTestUser(t))
+				cloneId, err := mysql.NewSaladRepository(testDbInstance).
+					Clone(context.Background(), saladId, newTestUser(t))
+				require.Nil(t, err)
+				recipe, err := recipeRepo.GetBySaladId(context.Background(), cloneId)
+				require.Nil(t, err)
+				version := lastVersion(t, recipe.ID)
+				recipe.TimeToCook += 10
+				err = recipeRepo.Update(context.Background(), recipe)
+				require.Nil(t, err)
+				return recipe.ID, version
+			},
+			check: func(t *testing.T, recipeId uuid.UUID) {
+				recipe, err := recipeRepo.GetById(context.Background(), recipeId)
+				require.Nil(t, err)
+				require.Equal(t, 10, recipe.TimeToCook)
+			},
+			wantErr: false,
+		}, // клонированный рецепт
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipeId, version := tt.prepare(t)
+			before := lastVersion(t, recipeId)
+
+			res, err := repo.Revert(context.Background(), recipeId, version)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+				require.Equal(t, before, lastVersion(t, recipeId))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, before+1, res)
+			}
+			tt.check(t, recipeId)
+		})
+	}
+}
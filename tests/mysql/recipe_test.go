@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func Test_recipeRepository_UpdateDetails(t *testing.T) {
+	repo := mysql.NewRecipeRepository(testDbInstance)
+	_, recipeId := newTestRecipe(t, newTestUser(t))
+	oven := &rDomain.Equipment{ID: uuid.UUID{1}, Name: "духовка"}
+	mixer := &rDomain.Equipment{ID: uuid.UUID{4}, Name: "миксер"}
+
+	tests := []struct {
+		name     string
+		details  *rDomain.RecipeDetails
+		wantErr  bool
+		errIs    error
+		expected *rDomain.RecipeDetails
+	}{
+		{
+			name: "успешное обновление",
+			details: &rDomain.RecipeDetails{
+				ID:          recipeId,
+				PrepMinutes: 5,
+				CookMinutes: 20,
+				RestMinutes: 10,
+				Difficulty:  2,
+				Equipment:   []*rDomain.Equipment{mixer, oven},
+			},
+			expected: &rDomain.RecipeDetails{
+				ID:           recipeId,
+				PrepMinutes:  5,
+				CookMinutes:  20,
+				RestMinutes:  10,
+				TotalMinutes: 35,
+				Difficulty:   2,
+				Equipment:    []*rDomain.Equipment{oven, mixer},
+			},
+		}, // успешное обновление
+		{
+			name: "сброс времени и оборудования",
+			details: &rDomain.RecipeDetails{
+				ID:         recipeId,
+				Difficulty: 1,
+			},
+			expected: &rDomain.RecipeDetails{
+				ID:           recipeId,
+				TotalMinutes: 10,
+				Difficulty:   1,
+				Equipment:    []*rDomain.Equipment{},
+			},
+		}, // сброс времени и оборудования
+		{
+			name: "несуществующий рецепт",
+			details: &rDomain.RecipeDetails{
+				ID:         uuid.New(),
+				Difficulty: 1,
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующий рецепт
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.UpdateDetails(context.Background(), tt.details)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				details, err := repo.GetDetails(context.Background(), tt.details.ID)
+				require.Nil(t, err)
+				if details.Equipment == nil {
+					details.Equipment = []*rDomain.Equipment{}
+				}
+				require.Equal(t, tt.expected, details)
+			}
+		})
+	}
+}
+
+func Test_recipeRepository_GetAllFiltered(t *testing.T) {
+	repo := mysql.NewRecipeRepository(testDbInstance)
+	saladRepo := mysql.NewSaladRepository(testDbInstance)
+	saladTypeRepo := mysql.NewSaladTypeRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+
+	typeId, err := saladTypeRepo.Create(context.Background(), &domain.SaladType{Name: "фильтр " + uuid.NewString()[:8]})
+	require.Nil(t, err)
+	newRecipe := func(rating float32, details *rDomain.RecipeDetails, ingredient string) (uuid.UUID, uuid.UUID) {
+		saladId, recipeId := newTestRecipe(t, newTestUser(t))
+		err := saladTypeRepo.Link(context.Background(), saladId, typeId)
+		require.Nil(t, err)
+		err = testDbInstance.Table("recipe").
+			Where("id = ?", recipeId).
+			Update("rating", rating).Error
+		require.Nil(t, err)
+		details.ID = recipeId
+		err = repo.UpdateDetails(context.Background(), details)
+		require.Nil(t, err)
+		_, err = ingredientRepo.Link(context.Background(), recipeId, idByName(t, "ingredient", ingredient))
+		require.Nil(t, err)
+		return saladId, recipeId
+	}
+	quickSaladId, quickId := newRecipe(3, &rDomain.RecipeDetails{
+		PrepMinutes: 10,
+		Difficulty:  1,
+		Equipment:   []*rDomain.Equipment{{ID: uuid.UUID{1}}},
+	}, "морковь")
+	slowSaladId, slowId := newRecipe(4, &rDomain.RecipeDetails{
+		CookMinutes: 60,
+		Difficulty:  3,
+	}, "говядина")
+
+	tests := []struct {
+		name     string
+		filter   *rDomain.RecipeListFilter
+		expected []uuid.UUID
+		salads   []uuid.UUID
+		pages    int
+	}{
+		{
+			name:     "только по типу",
+			filter:   &rDomain.RecipeListFilter{},
+			expected: []uuid.UUID{slowId, quickId},
+			salads:   []uuid.UUID{slowSaladId, quickSaladId},
+			pages:    1,
+		}, // только по типу
+		{
+			name:     "по общему времени",
+			filter:   &rDomain.RecipeListFilter{MaxTotalMinutes: 30},
+			expected: []uuid.UUID{quickId},
+			salads:   []uuid.UUID{quickSaladId},
+			pages:    1,
+		}, // по общему времени
+		{
+			name:     "по сложности",
+			filter:   &rDomain.RecipeListFilter{Difficulties: []int{2, 3}},
+			expected: []uuid.UUID{slowId},
+			salads:   []uuid.UUID{slowSaladId},
+			pages:    1,
+		}, // по сложности
+		{
+			name:     "без оборудования",
+			filter:   &rDomain.RecipeListFilter{ExcludedEquipment: []uuid.UUID{{1}}},
+			expected: []uuid.UUID{slowId},
+			salads:   []uuid.UUID{slowSaladId},
+			pages:    1,
+		}, // без оборудования
+		{
+			name: "по доступным ингредиентам",
+			filter: &rDomain.RecipeListFilter{RecipeFilter: domain.RecipeFilter{
+				AvailableIngredients: []uuid.UUID{idByName(t, "ingredient", "морковь")},
+			}},
+			expected: []uuid.UUID{quickId},
+			salads:   []uuid.UUID{quickSaladId},
+			pages:    1,
+		}, // по доступным ингредиентам
+		{
+			name:     "по рейтингу",
+			filter:   &rDomain.RecipeListFilter{RecipeFilter: domain.RecipeFilter{MinRate: 4}},
+			expected: []uuid.UUID{slowId},
+			salads:   []uuid.UUID{slowSaladId},
+			pages:    1,
+		}, // по рейтингу
+		{
+			name: "по статусу",
+			filter: &rDomain.RecipeListFilter{RecipeFilter: domain.RecipeFilter{
+				Status: domain.PublishedSaladStatus,
+			}},
+			expected: []uuid.UUID{},
+			salads:   []uuid.UUID{},
+			pages:    0,
+		}, // по статусу
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.SaladTypes = []uuid.UUID{typeId}
+
+			recipes, pages, err := repo.GetAllFiltered(context.Background(), tt.filter, 1)
+			require.Nil(t, err)
+			require.Equal(t, tt.pages, pages)
+			ids := make([]uuid.UUID, 0)
+			for _, recipe := range recipes {
+				ids = append(ids, recipe.ID)
+			}
+			require.Equal(t, tt.expected, ids)
+
+			salads, pages, err := saladRepo.GetAllFiltered(context.Background(), tt.filter, 1)
+			require.Nil(t, err)
+			require.Equal(t, tt.pages, pages)
+			ids = make([]uuid.UUID, 0)
+			for _, salad := range salads {
+				ids = append(ids, salad.ID)
+			}
+			require.Equal(t, tt.salads, ids)
+		})
+	}
+}
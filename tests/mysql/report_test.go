@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func Test_reportRepository_Create(t *testing.T) {
+	repo := mysql.NewReportRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	firstReporterId := newTestUser(t)
+	secondReporterId := newTestUser(t)
+
+	tests := []struct {
+		name    string
+		report  *rDomain.Report
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name: "успешное создание",
+			report: &rDomain.Report{
+				ReporterID: firstReporterId,
+				TargetKind: rDomain.ReportTargetSalad,
+				TargetID:   saladId,
+				Reason:     rDomain.ReportReasonSpam,
+			},
+		}, // успешное создание
+		{
+			name: "повторная жалоба",
+			report: &rDomain.Report{
+				ReporterID: firstReporterId,
+				TargetKind: rDomain.ReportTargetSalad,
+				TargetID:   saladId,
+				Reason:     rDomain.ReportReasonOffensive,
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrDuplicateReport,
+		}, // повторная жалоба
+		{
+			name: "жалоба другого пользователя",
+			report: &rDomain.Report{
+				ReporterID: secondReporterId,
+				TargetKind: rDomain.ReportTargetSalad,
+				TargetID:   saladId,
+				Reason:     rDomain.ReportReasonOffensive,
+				Details:    "details",
+			},
+		}, // жалоба другого пользователя
+		{
+			name: "неизвестный вид цели",
+			report: &rDomain.Report{
+				ReporterID: firstReporterId,
+				TargetKind: "user",
+				TargetID:   saladId,
+				Reason:     rDomain.ReportReasonSpam,
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrUnknownReportTarget,
+		}, // неизвестный вид цели
+		{
+			name: "неизвестная причина",
+			report: &rDomain.Report{
+				ReporterID: firstReporterId,
+				TargetKind: rDomain.ReportTargetSalad,
+				TargetID:   saladId,
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrUnknownReportReason,
+		}, // неизвестная причина
+		{
+			name: "несуществующая цель",
+			report: &rDomain.Report{
+				ReporterID: firstReporterId,
+				TargetKind: rDomain.ReportTargetComment,
+				TargetID:   uuid.New(),
+				Reason:     rDomain.ReportReasonSpam,
+			},
+			wantErr: true,
+			errIs:   gorm.ErrRecordNotFound,
+		}, // несуществующая цель
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := repo.Create(context.Background(), tt.report)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				report, err := repo.GetById(context.Background(), id)
+				require.Nil(t, err)
+				require.Equal(t, rDomain.ReportStatusOpen, report.Status)
+				require.Equal(t, tt.report.Details, report.Details)
+			}
+		})
+	}
+
+	summary, err := repo.GetSummary(context.Background(), rDomain.ReportTargetSalad, saladId)
+	require.Nil(t, err)
+	require.Equal(t, 2, summary.Count)
+	require.Equal(t, map[int]int{
+		rDomain.ReportReasonSpam:      1,
+		rDomain.ReportReasonOffensive: 1,
+	}, summary.Reasons)
+}
+
+func Test_reportRepository_Resolve(t *testing.T) {
+	repo := mysql.NewReportRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	reporterIds := []uuid.UUID{newTestUser(t), newTestUser(t)}
+	moderatorId := newTestUser(t)
+	for _, reporterId := range reporterIds {
+		_, err := repo.Create(context.Background(), &rDomain.Report{
+			ReporterID: reporterId,
+			TargetKind: rDomain.ReportTargetSalad,
+			TargetID:   saladId,
+			Reason:     rDomain.ReportReasonSpam,
+		})
+		require.Nil(t, err)
+	}
+
+	tests := []struct {
+		name     string
+		targetId uuid.UUID
+		action   int
+		wantErr  bool
+		errIs    error
+		expected int
+	}{
+		{
+			name:     "неизвестное действие",
+			targetId: saladId,
+			action:   rDomain.ReportActionWarnAuthor + 1,
+			wantErr:  true,
+			errIs:    rDomain.ErrUnknownReportAction,
+		}, // неизвестное действие
+		{
+			name:     "несуществующая цель",
+			targetId: uuid.New(),
+			action:   rDomain.ReportActionHide,
+			expected: 0,
+		}, // несуществующая цель
+		{
+			name:     "успешное закрытие",
+			targetId: saladId,
+			action:   rDomain.ReportActionHide,
+			expected: 2,
+		}, // успешное закрытие
+		{
+			name:     "повторное закрытие",
+			targetId: saladId,
+			action:   rDomain.ReportActionDismiss,
+			expected: 0,
+		}, // повторное закрытие
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.Resolve(context.Background(), rDomain.ReportTargetSalad, tt.targetId, tt.action, moderatorId)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, count)
+			}
+		})
+	}
+
+	reports, err := repo.GetByTarget(context.Background(), rDomain.ReportTargetSalad, saladId)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(reports))
+	for _, report := range reports {
+		require.Equal(t, rDomain.ReportStatusResolved, report.Status)
+		require.Equal(t, rDomain.ReportActionHide, *report.Action)
+		require.Equal(t, uuid.NullUUID{UUID: moderatorId, Valid: true}, report.ModeratorID)
+		require.NotNil(t, report.ResolvedAt)
+	}
+	_, err = repo.GetSummary(context.Background(), rDomain.ReportTargetSalad, saladId)
+	require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	_, err = repo.Create(context.Background(), &rDomain.Report{
+		ReporterID: reporterIds[0],
+		TargetKind: rDomain.ReportTargetSalad,
+		TargetID:   saladId,
+		Reason:     rDomain.ReportReasonOther,
+	})
+	require.Nil(t, err)
+	summary, err := repo.GetSummary(context.Background(), rDomain.ReportTargetSalad, saladId)
+	require.Nil(t, err)
+	require.Equal(t, 1, summary.Count)
+	require.Equal(t, map[int]int{rDomain.ReportReasonOther: 1}, summary.Reasons)
+}
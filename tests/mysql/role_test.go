@@ -0,0 +1,239 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/Mx1q/ppo_services/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"net/mail"
+	"testing"
+)
+
+func Test_roleRepository_Permissions(t *testing.T) {
+	authRepo := mysql.NewAuthRepository(testDbInstance)
+	repo := mysql.NewRoleRepository(testDbInstance)
+
+	user, err := authRepo.GetByUsername(context.Background(), "testingUser")
+	require.Nil(t, err)
+
+	tests := []struct {
+		name       string
+		roles      []string
+		permission string
+		expected   bool
+	}{
+		{
+			name:       "право роли по умолчанию",
+			permission: rDomain.PermissionCreateComment,
+			expected:   true,
+		}, // право роли по умолчанию
+		{
+			name:       "нет права",
+			permission: rDomain.PermissionEditKeywords,
+			expected:   false,
+		}, // нет права
+		{
+			name:       "право добавленной роли",
+			roles:      []string{rDomain.RoleUser, rDomain.RoleModerator},
+			permission: rDomain.PermissionEditKeywords,
+			expected:   true,
+		}, // право добавленной роли
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Assign(context.Background(), user.ID, tt.roles...)
+			require.Nil(t, err)
+
+			res, err := repo.HasPermission(context.Background(), user.ID, tt.permission)
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, res)
+
+			permissions, err := repo.GetUserPermissions(context.Background(), user.ID)
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, contains(permissions, tt.permission))
+		})
+	}
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_roleRepository_UserRoleSync(t *testing.T) {
+	authRepo := mysql.NewAuthRepository(testDbInstance)
+	userRepo := mysql.NewUserRepository(testDbInstance)
+	repo := mysql.NewRoleRepository(testDbInstance)
+	suffix := uuid.NewString()[:8]
+
+	_, err := authRepo.Register(context.Background(), &domain.User{
+		Name:     "chef" + suffix,
+		Username: "chef" + suffix,
+		Password: "pass",
+		Email:    mail.Address{Address: "chef" + suffix + "@mail.ru"},
+		Role:     "chef" + suffix,
+	})
+	require.True(t, errors.Is(err, rDomain.ErrUnknownRole))
+	_, err = authRepo.GetByUsername(context.Background(), "chef"+suffix)
+	require.NotNil(t, err)
+
+	registeredId, err := authRepo.Register(context.Background(), &domain.User{
+		Name:     "cook" + suffix,
+		Username: "cook" + suffix,
+		Password: "pass",
+		Email:    mail.Address{Address: "cook" + suffix + "@mail.ru"},
+	})
+	require.Nil(t, err)
+	createdId, err := userRepo.Create(context.Background(), &domain.User{
+		Name:     "admin" + suffix,
+		Username: "admin" + suffix,
+		Password: "pass",
+		Email:    mail.Address{Address: "admin" + suffix + "@mail.ru"},
+		Role:     rDomain.RoleAdmin,
+	})
+	require.Nil(t, err)
+
+	update := func(userId uuid.UUID, role string) error {
+		user, err := userRepo.GetById(context.Background(), userId)
+		if err != nil {
+			return err
+		}
+		user.Name += "!"
+		user.Role = role
+		return userRepo.Update(context.Background(), user)
+	}
+
+	tests := []struct {
+		name     string
+		userId   uuid.UUID
+		change   func(userId uuid.UUID) error
+		roles    []string
+		expected string
+		wantErr  bool
+		errIs    error
+	}{
+		{
+			name:     "регистрация с ролью по умолчанию",
+			userId:   registeredId,
+			change:   func(uuid.UUID) error { return nil },
+			roles:    []string{rDomain.RoleUser},
+			expected: rDomain.RoleUser,
+		}, // регистрация с ролью по умолчанию
+		{
+			name:   "назначение более сильной роли",
+			userId: registeredId,
+			change: func(userId uuid.UUID) error {
+				return repo.Assign(context.Background(), userId, rDomain.RoleModerator)
+			},
+			roles:    []string{rDomain.RoleUser, rDomain.RoleModerator},
+			expected: rDomain.RoleModerator,
+		}, // назначение более сильной роли
+		{
+			name:   "назначение роли не из справочника",
+			userId: registeredId,
+			change: func(userId uuid.UUID) error {
+				return repo.Assign(context.Background(), userId, "chef"+suffix)
+			},
+			roles:    []string{rDomain.RoleUser, rDomain.RoleModerator},
+			expected: rDomain.RoleModerator,
+			wantErr:  true,
+			errIs:    rDomain.ErrUnknownRole,
+		}, // назначение роли не из справочника
+		{
+			name:   "отзыв основной роли",
+			userId: registeredId,
+			change: func(userId uuid.UUID) error {
+				return repo.Revoke(context.Background(), userId, rDomain.RoleModerator)
+			},
+			roles:    []string{rDomain.RoleUser},
+			expected: rDomain.RoleUser,
+		}, // отзыв основной роли
+		{
+			name:   "отзыв последней роли",
+			userId: registeredId,
+			change: func(userId uuid.UUID) error {
+				return repo.Revoke(context.Background(), userId, rDomain.RoleUser)
+			},
+			roles:    []string{},
+			expected: rDomain.DefaultRole,
+		}, // отзыв последней роли
+		{
+			name:     "создание пользователя",
+			userId:   createdId,
+			change:   func(uuid.UUID) error { return nil },
+			roles:    []string{rDomain.RoleAdmin},
+			expected: rDomain.RoleAdmin,
+		}, // создание пользователя
+		{
+			name:   "обновление с той же ролью",
+			userId: createdId,
+			change: func(userId uuid.UUID) error {
+				err := repo.Assign(context.Background(), userId, rDomain.RoleModerator)
+				if err != nil {
+					return err
+				}
+				return update(userId, rDomain.RoleAdmin)
+			},
+			roles:    []string{rDomain.RoleAdmin, rDomain.RoleModerator},
+			expected: rDomain.RoleAdmin,
+		}, // обновление с той же ролью
+		{
+			name:   "обновление без роли",
+			userId: createdId,
+			change: func(userId uuid.UUID) error {
+				return update(userId, "")
+			},
+			roles:    []string{rDomain.RoleAdmin, rDomain.RoleModerator},
+			expected: rDomain.RoleAdmin,
+		}, // обновление без роли
+		{
+			name:   "обновление с ролью не из справочника",
+			userId: createdId,
+			change: func(userId uuid.UUID) error {
+				return update(userId, "chef"+suffix)
+			},
+			roles:    []string{rDomain.RoleAdmin, rDomain.RoleModerator},
+			expected: rDomain.RoleAdmin,
+			wantErr:  true,
+			errIs:    rDomain.ErrUnknownRole,
+		}, // обновление с ролью не из справочника
+		{
+			name:   "смена роли при обновлении",
+			userId: createdId,
+			change: func(userId uuid.UUID) error {
+				return update(userId, rDomain.RoleUser)
+			},
+			roles:    []string{rDomain.RoleUser},
+			expected: rDomain.RoleUser,
+		}, // смена роли при обновлении
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change(tt.userId)
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+			}
+
+			roles, err := repo.GetUserRoles(context.Background(), tt.userId)
+			require.Nil(t, err)
+			names := make([]string, 0, len(roles))
+			for _, role := range roles {
+				names = append(names, role.Name)
+			}
+			require.ElementsMatch(t, tt.roles, names)
+
+			user, err := userRepo.GetById(context.Background(), tt.userId)
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, user.Role)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package tests
 
 import (
 	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
 	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
 	"github.com/Mx1q/ppo_services/domain"
 	"github.com/google/uuid"
@@ -181,3 +183,166 @@ func Test_saladRepository_GetAll(t *testing.T) {
 		})
 	}
 }
+
+func Test_saladRepository_Clone(t *testing.T) {
+	repo := mysql.NewSaladRepository(testDbInstance)
+	recipeRepo := mysql.NewRecipeRepository(testDbInstance)
+	stepRepo := mysql.NewRecipeStepRepository(testDbInstance)
+	ingredientRepo := mysql.NewIngredientRepository(testDbInstance)
+
+	authorId := newTestUser(t)
+	saladId, recipeId := newTestRecipe(t, authorId)
+	stepId, err := stepRepo.Create(context.Background(), &domain.RecipeStep{
+		RecipeID:    recipeId,
+		Name:        "нарезать",
+		Description: "нарезать морковь",
+	})
+	require.Nil(t, err)
+	linkId, err := ingredientRepo.Link(context.Background(), recipeId, idByName(t, "ingredient", "морковь"))
+	require.Nil(t, err)
+	err = stepRepo.LinkIngredient(context.Background(), stepId, linkId)
+	require.Nil(t, err)
+
+	forkAuthorId := newTestUser(t)
+	cloneId, err := repo.Clone(context.Background(), saladId, forkAuthorId)
+	require.Nil(t, err)
+	require.NotEqual(t, saladId, cloneId)
+
+	source, err := repo.GetById(context.Background(), saladId)
+	require.Nil(t, err)
+	clone, err := repo.GetById(context.Background(), cloneId)
+	require.Nil(t, err)
+	require.Equal(t, forkAuthorId, clone.AuthorID)
+	require.Equal(t, source.Name, clone.Name)
+
+	cloneRecipe, err := recipeRepo.GetBySaladId(context.Background(), cloneId)
+	require.Nil(t, err)
+	require.NotEqual(t, recipeId, cloneRecipe.ID)
+	require.Equal(t, domain.EditingSaladStatus, cloneRecipe.Status)
+
+	sections, err := stepRepo.GetSections(context.Background(), cloneRecipe.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sections))
+	require.Equal(t, 1, len(sections[0].Steps))
+	require.NotEqual(t, stepId, sections[0].Steps[0].Step.ID)
+	require.Equal(t, "нарезать", sections[0].Steps[0].Step.Name)
+	require.Equal(t, 1, len(sections[0].Steps[0].Ingredients))
+	require.NotEqual(t, linkId, sections[0].Steps[0].Ingredients[0].LinkId)
+	require.Equal(t, idByName(t, "ingredient", "морковь"), sections[0].Steps[0].Ingredients[0].IngredientId)
+
+	_, err = repo.Clone(context.Background(), uuid.New(), forkAuthorId)
+	require.NotNil(t, err)
+}
+
+func Test_saladRepository_GetParentAndForks(t *testing.T) {
+	repo := mysql.NewSaladRepository(testDbInstance)
+	saladId, _ := newTestRecipe(t, newTestUser(t))
+	forkId, err := repo.Clone(context.Background(), saladId, newTestUser(t))
+	require.Nil(t, err)
+	forkOfForkId, err := repo.Clone(context.Background(), forkId, newTestUser(t))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		saladId   uuid.UUID
+		wantErr   bool
+		errStr    error
+		parentId  uuid.UUID
+		forkIds   []uuid.UUID
+		forkPages int
+	}{
+		{
+			name:      "исходный салат",
+			saladId:   saladId,
+			wantErr:   true,
+			errStr:    errors.New("getting parent salad: record not found"),
+			forkIds:   []uuid.UUID{forkId},
+			forkPages: 1,
+		}, // исходный салат
+		{
+			name:      "ответвление",
+			saladId:   forkId,
+			parentId:  saladId,
+			forkIds:   []uuid.UUID{forkOfForkId},
+			forkPages: 1,
+		}, // ответвление
+		{
+			name:     "ответвление ответвления",
+			saladId:  forkOfForkId,
+			parentId: forkId,
+			forkIds:  []uuid.UUID{},
+		}, // ответвление ответвления
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, err := repo.GetParent(context.Background(), tt.saladId)
+			if tt.wantErr {
+				require.Equal(t, tt.errStr.Error(), err.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.parentId, parent.ID)
+			}
+
+			forks, pages, err := repo.GetForks(context.Background(), tt.saladId, 1)
+			require.Nil(t, err)
+			require.Equal(t, tt.forkPages, pages)
+			forkIds := make([]uuid.UUID, 0)
+			for _, fork := range forks {
+				forkIds = append(forkIds, fork.ID)
+			}
+			require.Equal(t, tt.forkIds, forkIds)
+		})
+	}
+}
+
+func Test_saladRepository_CreateRetry(t *testing.T) {
+	repo := mysql.NewSaladRepository(testDbInstance)
+	authorId := newTestUser(t)
+	saladId := uuid.New()
+
+	tests := []struct {
+		name    string
+		salad   *domain.Salad
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name: "создание с выбранным id",
+			salad: &domain.Salad{
+				ID:       saladId,
+				AuthorID: authorId,
+				Name:     "retried salad",
+			},
+			wantErr: false,
+		}, // создание с выбранным id
+		{
+			name: "повтор создания",
+			salad: &domain.Salad{
+				ID:       saladId,
+				AuthorID: authorId,
+				Name:     "retried salad",
+			},
+			wantErr: true,
+			errIs:   rDomain.ErrAlreadyExists,
+		}, // повтор создания
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := repo.Create(context.Background(), tt.salad)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, saladId, id)
+			}
+		})
+	}
+
+	var count int64
+	err := testDbInstance.Table("salad").
+		Where("id = ?", saladId).
+		Count(&count).Error
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+}
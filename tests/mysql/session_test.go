@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	rDomain "github.com/Mx1q/ppo_repoMysql/domain"
+	"github.com/Mx1q/ppo_repoMysql/repository/mySQL"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_sessionRepository_Create(t *testing.T) {
+	repo := mysql.NewSessionRepository(testDbInstance)
+	userId := newTestUser(t)
+	sessionId := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		session *rDomain.Session
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "создание с выбранным id",
+			session: &rDomain.Session{ID: sessionId, UserID: userId, ExpiresAt: expiresAt},
+			wantErr: false,
+		}, // создание с выбранным id
+		{
+			name:    "повтор создания",
+			session: &rDomain.Session{ID: sessionId, UserID: userId, ExpiresAt: expiresAt},
+			wantErr: true,
+			errIs:   rDomain.ErrAlreadyExists,
+		}, // повтор создания
+		{
+			name:    "несуществующий пользователь",
+			session: &rDomain.Session{UserID: uuid.New(), ExpiresAt: expiresAt},
+			wantErr: true,
+		}, // несуществующий пользователь
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := uuid.NewString()
+			id, err := repo.Create(context.Background(), tt.session, token)
+
+			if tt.wantErr {
+				require.NotNil(t, err)
+				if tt.errIs != nil {
+					require.True(t, errors.Is(err, tt.errIs))
+				}
+				_, err = repo.GetByRefreshToken(context.Background(), token)
+				require.True(t, errors.Is(err, rDomain.ErrSessionNotFound))
+			} else {
+				require.Nil(t, err)
+				session, err := repo.GetByRefreshToken(context.Background(), token)
+				require.Nil(t, err)
+				require.Equal(t, id, session.ID)
+				require.Equal(t, id, session.FamilyID)
+			}
+		})
+	}
+}
+
+func Test_sessionRepository_Rotate(t *testing.T) {
+	repo := mysql.NewSessionRepository(testDbInstance)
+	userId := newTestUser(t)
+	expiresAt := time.Now().Add(time.Hour)
+	familyId, firstToken := newTestSession(t, userId, expiresAt)
+	_, expiredToken := newTestSession(t, userId, time.Now().Add(-time.Minute))
+	secondToken, thirdToken := uuid.NewString(), uuid.NewString()
+
+	tests := []struct {
+		name     string
+		oldToken string
+		newToken string
+		wantErr  bool
+		errIs    error
+		active   int
+	}{
+		{
+			name:     "успешная замена",
+			oldToken: firstToken,
+			newToken: secondToken,
+			active:   1,
+		}, // успешная замена
+		{
+			name:     "повторная успешная замена",
+			oldToken: secondToken,
+			newToken: thirdToken,
+			active:   1,
+		}, // повторная успешная замена
+		{
+			name:     "неизвестный токен",
+			oldToken: uuid.NewString(),
+			newToken: uuid.NewString(),
+			wantErr:  true,
+			errIs:    rDomain.ErrSessionNotFound,
+			active:   1,
+		}, // неизвестный токен
+		{
+			name:     "истекшая сессия",
+			oldToken: expiredToken,
+			newToken: uuid.NewString(),
+			wantErr:  true,
+			errIs:    rDomain.ErrSessionExpired,
+			active:   1,
+		}, // истекшая сессия
+		{
+			name:     "повторное использование токена",
+			oldToken: firstToken,
+			newToken: uuid.NewString(),
+			wantErr:  true,
+			errIs:    rDomain.ErrRefreshTokenReused,
+			active:   0,
+		}, // повторное использование токена
+		{
+			name:     "отозванная сессия",
+			oldToken: thirdToken,
+			newToken: uuid.NewString(),
+			wantErr:  true,
+			errIs:    rDomain.ErrSessionRevoked,
+			active:   0,
+		}, // отозванная сессия
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := repo.Rotate(context.Background(), tt.oldToken, tt.newToken, expiresAt)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, familyId, session.FamilyID)
+				require.Equal(t, userId, session.UserID)
+				require.Equal(t, "device", session.Device)
+
+				old, err := repo.GetByRefreshToken(context.Background(), tt.oldToken)
+				require.Nil(t, err)
+				require.NotNil(t, old.RevokedAt)
+				require.Equal(t, uuid.NullUUID{UUID: session.ID, Valid: true}, old.ReplacedBy)
+			}
+			active, err := repo.GetActive(context.Background(), userId)
+			require.Nil(t, err)
+			require.Equal(t, tt.active, len(active))
+		})
+	}
+}
+
+func Test_sessionRepository_RevokeAll(t *testing.T) {
+	repo := mysql.NewSessionRepository(testDbInstance)
+	userId := newTestUser(t)
+	otherUserId := newTestUser(t)
+	expiresAt := time.Now().Add(time.Hour)
+	revokedId, _ := newTestSession(t, userId, expiresAt)
+	newTestSession(t, userId, expiresAt)
+	newTestSession(t, userId, expiresAt)
+	newTestSession(t, otherUserId, expiresAt)
+
+	err := repo.Revoke(context.Background(), revokedId)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		userId   uuid.UUID
+		expected int
+	}{
+		{
+			name:     "отзыв всех сессий",
+			userId:   userId,
+			expected: 2,
+		}, // отзыв всех сессий
+		{
+			name:     "повторный отзыв",
+			userId:   userId,
+			expected: 0,
+		}, // повторный отзыв
+		{
+			name:     "пользователь без сессий",
+			userId:   uuid.New(),
+			expected: 0,
+		}, // пользователь без сессий
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.RevokeAll(context.Background(), tt.userId)
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, count)
+
+			active, err := repo.GetActive(context.Background(), userId)
+			require.Nil(t, err)
+			require.Equal(t, 0, len(active))
+		})
+	}
+
+	active, err := repo.GetActive(context.Background(), otherUserId)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(active))
+}
+
+func Test_sessionRepository_PurgeExpired(t *testing.T) {
+	repo := mysql.NewSessionRepository(testDbInstance)
+	userId := newTestUser(t)
+	_, expiredToken := newTestSession(t, userId, time.Now().Add(-time.Minute))
+	revokedId, revokedToken := newTestSession(t, userId, time.Now().Add(time.Hour))
+	_, activeToken := newTestSession(t, userId, time.Now().Add(time.Hour))
+	err := repo.Revoke(context.Background(), revokedId)
+	require.Nil(t, err)
+
+	count, err := repo.PurgeExpired(context.Background())
+	require.Nil(t, err)
+	require.GreaterOrEqual(t, count, 1)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "истекшая сессия удалена",
+			token:   expiredToken,
+			wantErr: true,
+			errIs:   rDomain.ErrSessionNotFound,
+		}, // истекшая сессия удалена
+		{
+			name:  "отозванная сессия сохранена",
+			token: revokedToken,
+		}, // отозванная сессия сохранена
+		{
+			name:  "активная сессия сохранена",
+			token: activeToken,
+		}, // активная сессия сохранена
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.GetByRefreshToken(context.Background(), tt.token)
+
+			if tt.wantErr {
+				require.True(t, errors.Is(err, tt.errIs))
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+
+	count, err = repo.PurgeExpired(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+}